@@ -0,0 +1,235 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// NacosBridgeConfig 的 Ready 条件
+	ConditionReady = "Ready"
+
+	// 存在多个 NacosBridgeConfig 时未被使用的配置
+	ReasonIgnored = "Ignored"
+	// 配置无效
+	ReasonInvalid = "Invalid"
+	// 注册中心同步失败
+	ReasonSyncFailed = "SyncFailed"
+	// 同步成功
+	ReasonSynced = "Synced"
+)
+
+// RegistrySpec 注册中心连接配置
+type RegistrySpec struct {
+	// 注册中心类型, 目前只支持 nacos
+	// +kubebuilder:validation:Enum=nacos
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MinLength=1
+	Address string `json:"address"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=8848
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// 保存用户名和密码的 Secret
+	// +optional
+	CredentialsRef *CredentialsRef `json:"credentialsRef,omitempty"`
+
+	// 导出的 Kubernetes 命名空间, 为空时导出全部
+	// +optional
+	WatchNamespaces []string `json:"watchNamespaces,omitempty"`
+}
+
+// CredentialsRef 引用保存注册中心凭据的 Secret, Secret 必须位于桥接所在命名空间
+type CredentialsRef struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// +kubebuilder:default=username
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// +kubebuilder:default=password
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// NodeSpec NodePort 服务的节点选择
+type NodeSpec struct {
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// +kubebuilder:validation:Enum=InternalIP;ExternalIP;Hostname
+	// +optional
+	AddressType string `json:"addressType,omitempty"`
+
+	// +optional
+	IncludeNotReady bool `json:"includeNotReady,omitempty"`
+
+	// +optional
+	IncludeUnschedulable bool `json:"includeUnschedulable,omitempty"`
+
+	// +optional
+	IncludeTainted bool `json:"includeTainted,omitempty"`
+
+	// 下线节点上的实例保留注册但被禁用的时长
+	// +optional
+	DrainGracePeriod *metav1.Duration `json:"drainGracePeriod,omitempty"`
+
+	// +kubebuilder:validation:Enum=zone;region;none
+	// +optional
+	TopologyCluster string `json:"topologyCluster,omitempty"`
+}
+
+// NamespaceMappingSpec Kubernetes 命名空间到 Nacos 命名空间 ID 的映射
+type NamespaceMappingSpec struct {
+	// +optional
+	Identity bool `json:"identity,omitempty"`
+
+	// +optional
+	Namespaces []NamespaceMappingEntry `json:"namespaces,omitempty"`
+
+	// +optional
+	Default string `json:"default,omitempty"`
+}
+
+type NamespaceMappingEntry struct {
+	// 命名空间名称, 支持通配符
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:MinLength=1
+	NacosNamespace string `json:"nacosNamespace"`
+}
+
+// RuleSpec 自动注册规则
+type RuleSpec struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// +optional
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// +kubebuilder:validation:Enum=cluster;external;gateway
+	// +optional
+	ServiceType string `json:"serviceType,omitempty"`
+	// +optional
+	OpenPort string `json:"openport,omitempty"`
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// PolicySpec CEL 导出策略
+type PolicySpec struct {
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	Expression string `json:"expression"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NacosBridgeConfigSpec 桥接配置
+type NacosBridgeConfigSpec struct {
+	// +kubebuilder:validation:MinItems=1
+	Registries []RegistrySpec `json:"registries"`
+
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+	// +optional
+	ClusterDomain string `json:"clusterDomain,omitempty"`
+
+	// +kubebuilder:validation:Enum=fqdn;short;clusterip;template
+	// +optional
+	AddressType string `json:"addressType,omitempty"`
+	// +optional
+	AddressTemplate string `json:"addressTemplate,omitempty"`
+
+	// +optional
+	Nodes NodeSpec `json:"nodes,omitempty"`
+
+	// +kubebuilder:validation:Enum=ipv4;ipv6;both
+	// +optional
+	IPFamily string `json:"ipFamily,omitempty"`
+
+	// +optional
+	ServiceNameTemplate string `json:"serviceNameTemplate,omitempty"`
+	// +optional
+	GroupTemplate string `json:"groupTemplate,omitempty"`
+	// +optional
+	MetadataTemplates map[string]string `json:"metadataTemplates,omitempty"`
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// +optional
+	AutoMetadata []string `json:"autoMetadata,omitempty"`
+
+	// +optional
+	NamespaceMapping NamespaceMappingSpec `json:"namespaceMapping,omitempty"`
+	// +optional
+	Rules []RuleSpec `json:"rules,omitempty"`
+	// +optional
+	Policies []PolicySpec `json:"policies,omitempty"`
+}
+
+// RegistryStatus 注册中心的连接状态
+type RegistryStatus struct {
+	Name      string `json:"name"`
+	Connected bool   `json:"connected"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+}
+
+// NacosBridgeConfigStatus 配置的同步状态
+type NacosBridgeConfigStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// +optional
+	Registries []RegistryStatus `json:"registries,omitempty"`
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=nbc
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NacosBridgeConfig 集群级别的桥接配置, 替代带有 nacosbridge.io/config 标签的 ConfigMap
+type NacosBridgeConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosBridgeConfigSpec   `json:"spec,omitempty"`
+	Status NacosBridgeConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosBridgeConfigList contains a list of NacosBridgeConfig.
+type NacosBridgeConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosBridgeConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosBridgeConfig{}, &NacosBridgeConfigList{})
+}
@@ -0,0 +1,125 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// 实例的 Registered 条件
+	ConditionRegistered = "Registered"
+
+	// 实例已注册
+	ReasonRegistered = "Registered"
+	// 实例注册失败
+	ReasonRegisterFailed = "RegisterFailed"
+	// 所在命名空间未导出到目标注册中心
+	ReasonNamespaceNotWatched = "NamespaceNotWatched"
+	// spec.namespace 与所在命名空间的映射结果不一致
+	ReasonNamespaceNotAllowed = "NamespaceNotAllowed"
+	// 实例端口被导出策略拒绝
+	ReasonPolicyRejected = "PolicyRejected"
+)
+
+// RegistrationInstance 显式注册的实例
+type RegistrationInstance struct {
+	// +kubebuilder:validation:MinLength=1
+	IP string `json:"ip"`
+
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// 实例权重, 默认 10
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10000
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+
+	// 实例元数据, 覆盖 spec.metadata 中的同名键
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// 为 false 时实例保留注册但被禁用
+	// +kubebuilder:default=true
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// NacosRegistrationSpec 不依赖 Kubernetes Service 的显式注册
+type NacosRegistrationSpec struct {
+	// 目标注册中心
+	// +kubebuilder:validation:Enum=nacos
+	// +kubebuilder:default=nacos
+	// +optional
+	Registry string `json:"registry,omitempty"`
+
+	// +kubebuilder:validation:MinLength=1
+	ServiceName string `json:"serviceName"`
+
+	// Nacos 命名空间 ID, 为空时按 namespaceMapping 解析所在命名空间; 设置时必须与解析结果一致
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// +kubebuilder:default=DEFAULT_GROUP
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Nacos 集群名称, 为空时使用 Nacos 默认集群
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// 所有实例共用的元数据
+	// +optional
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Instances []RegistrationInstance `json:"instances"`
+}
+
+// InstanceStatus 实例的注册状态
+type InstanceStatus struct {
+	IP   string `json:"ip"`
+	Port int32  `json:"port"`
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NacosRegistrationStatus 显式注册的同步状态
+type NacosRegistrationStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	Instances []InstanceStatus `json:"instances,omitempty"`
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=nreg
+// +kubebuilder:printcolumn:name="Service",type=string,JSONPath=`.spec.serviceName`
+// +kubebuilder:printcolumn:name="Group",type=string,JSONPath=`.spec.group`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NacosRegistration 显式注册到 Nacos 的服务, 用于虚拟机、外部服务等没有 Kubernetes Service 的场景
+type NacosRegistration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NacosRegistrationSpec   `json:"spec,omitempty"`
+	Status NacosRegistrationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NacosRegistrationList contains a list of NacosRegistration.
+type NacosRegistrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NacosRegistration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NacosRegistration{}, &NacosRegistrationList{})
+}
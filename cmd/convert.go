@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"nacosbridge/service"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// runConvert 将 Service 清单中的 v1 标签/注解改写为 v2 键名
+//
+//	nacosbridge convert -f svc.yaml [-w]
+//	nacosbridge convert --live [-n namespace] [--dry-run]
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	var files stringSlice
+	fs.Var(&files, "f", "Service manifest to convert, - for stdin (repeatable)")
+	write := fs.Bool("w", false, "write the result back to the manifest files instead of stdout")
+	live := fs.Bool("live", false, "convert live Services in the cluster")
+	namespace := fs.String("n", "", "namespace of live Services, all namespaces when empty")
+	dryRun := fs.Bool("dry-run", false, "only print the live Services that would be converted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *live {
+		return convertLive(*namespace, *dryRun)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("either -f or --live is required")
+	}
+	for _, file := range files {
+		if err := convertFile(file, *write); err != nil {
+			return fmt.Errorf("failed to convert %s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+func convertFile(file string, write bool) error {
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	docs := make([][]byte, 0)
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(in))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		converted, err := convertDocument(doc)
+		if err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(converted, []byte("\n")) {
+			converted = append(converted, '\n')
+		}
+		docs = append(docs, converted)
+	}
+
+	out := bytes.Join(docs, []byte("---\n"))
+	if write && file != "-" {
+		return os.WriteFile(file, out, 0o644)
+	}
+	_, err := os.Stdout.Write(out)
+	return err
+}
+
+// convertDocument 只改写 Service, 其它资源原样输出
+func convertDocument(doc []byte) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(doc, &obj.Object); err != nil {
+		return nil, err
+	}
+	if obj.GetKind() != "Service" {
+		return doc, nil
+	}
+
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if !service.ConvertSettings(labels, annotations) {
+		return doc, nil
+	}
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
+	}
+	if len(annotations) > 0 {
+		obj.SetAnnotations(annotations)
+	}
+	return yaml.Marshal(obj.Object)
+}
+
+func convertLive(namespace string, dryRun bool) error {
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	services := &corev1.ServiceList{}
+	if err := c.List(ctx, services, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		keys := service.DeprecatedSettings(svc.Labels, svc.Annotations)
+		if len(keys) == 0 {
+			continue
+		}
+		fmt.Printf("%s/%s: %s\n", svc.Namespace, svc.Name, strings.Join(keys, ", "))
+		if dryRun {
+			continue
+		}
+		if svc.Labels == nil {
+			svc.Labels = make(map[string]string)
+		}
+		if svc.Annotations == nil {
+			svc.Annotations = make(map[string]string)
+		}
+		service.ConvertSettings(svc.Labels, svc.Annotations)
+		if err := c.Update(ctx, svc); err != nil {
+			return fmt.Errorf("failed to update service %s/%s: %v", svc.Namespace, svc.Name, err)
+		}
+	}
+	return nil
+}
+
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
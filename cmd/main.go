@@ -18,16 +18,22 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"nacosbridge/api/v1alpha1"
 	"nacosbridge/controller"
 	"nacosbridge/service"
 	"os"
+	"strings"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
@@ -36,22 +42,66 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+
+	// 构建时通过 ldflags 写入
+	version     string
+	commit      string
+	nacosbridge string
 )
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "convert":
+			run = runConvert
+		case "maintenance":
+			run = runMaintenance
+		case "migrate-config":
+			run = runMigrateConfig
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	configFile := flag.String("config", "", "path to a config file in JSON or YAML, replaces the NacosBridgeConfig and the global config ConfigMaps")
+	metricsAddr := flag.String("metrics-bind-address", ":9090", "address the metrics endpoint binds to, 0 disables it")
+	pprofAddr := flag.String("pprof-bind-address", "", "address the pprof endpoint binds to, defaults to the metrics address, 0 disables it")
+	probeAddr := flag.String("health-probe-bind-address", "0", "address the health probe endpoint binds to, 0 disables it")
+	leaderElect := flag.Bool("leader-elect", false, "enable leader election, only the leader syncs to the registries")
+	delay := flag.Duration("debounce-delay", service.DELAY, "how long to wait after the last change before syncing")
+	maxWait := flag.Duration("debounce-max-wait", 0, "longest time to wait after the first pending change before syncing, 0 means no limit")
+	resyncPeriod := flag.Duration("resync-period", 0, "interval of full syncs that register every instance again, 0 disables them")
+	watchNamespaces := flag.String("watch-namespaces", "", "comma-separated namespaces to watch, empty watches all namespaces")
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
+	if err := bindEnv(flag.CommandLine); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	if nacosbridge != "" {
+		service.Version = nacosbridge
+	}
+	setupLog.Info("starting nacosbridge", "version", service.Version, "tag", version, "commit", commit)
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		HealthProbeBindAddress: "0",
-		LeaderElection:         false,
+		Cache:                  cache.Options{DefaultNamespaces: cacheNamespaces(*watchNamespaces), ByObject: cacheObjects()},
+		HealthProbeBindAddress: *probeAddr,
+		LeaderElection:         *leaderElect,
 		LeaderElectionID:       "d33b1eea.nacosbridge.io",
 	})
 
@@ -60,7 +110,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	metric := service.NewMetrics(":9090")
+	metric := service.NewMetrics(*metricsAddr, *pprofAddr)
 	if err := mgr.Add(metric); err != nil {
 		setupLog.Error(err, "unable to add metric handler")
 		os.Exit(1)
@@ -76,11 +126,27 @@ func main() {
 		os.Exit(1)
 	}
 
-	handler := service.NewService(statusUpdater.Writer())
+	handler := service.NewService(statusUpdater.Writer(), service.ServerOptions{
+		Delay:        *delay,
+		MaxWait:      *maxWait,
+		ResyncPeriod: *resyncPeriod,
+	})
+	handler.InjectRecorder(mgr.GetEventRecorderFor("nacosbridge"))
+	if *configFile != "" {
+		handler.SetConfigFile(*configFile)
+	}
 	if err := mgr.Add(handler); err != nil {
 		setupLog.Error(err, "unable to add service handler")
 		os.Exit(1)
 	}
+	if err := (&controller.NacosBridgeConfig{Handler: handler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to setup nacosbridgeconfig controller")
+		os.Exit(1)
+	}
+	if err := (&controller.NacosRegistration{Handler: handler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to setup nacosregistration controller")
+		os.Exit(1)
+	}
 	if err := (&controller.ConfigMap{Handler: handler}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to setup configmap controller")
 		os.Exit(1)
@@ -93,6 +159,14 @@ func main() {
 		setupLog.Error(err, "unable to setup node controller")
 		os.Exit(1)
 	}
+	if err := (&controller.EndpointSlice{Handler: handler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to setup endpointslice controller")
+		os.Exit(1)
+	}
+	if err := (&controller.Namespace{Handler: handler}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to setup namespace controller")
+		os.Exit(1)
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -109,3 +183,48 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// bindEnv 使用 NACOSBRIDGE_ 开头的环境变量设置参数的默认值, 例如 --leader-elect 对应 NACOSBRIDGE_LEADER_ELECT
+// 命令行参数优先于环境变量
+func bindEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := service.CONFIG_ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok || err != nil {
+			return
+		}
+		if e := f.Value.Set(value); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, name, e)
+		}
+	})
+	return err
+}
+
+// cacheNamespaces 返回需要监听的命名空间, NacosBridge 所在命名空间中的全局配置始终被监听
+func cacheNamespaces(namespaces string) map[string]cache.Config {
+	if namespaces == "" {
+		return nil
+	}
+	result := make(map[string]cache.Config)
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			result[ns] = cache.Config{}
+		}
+	}
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		result[ns] = cache.Config{}
+	}
+	return result
+}
+
+// cacheObjects 凭据 Secret 只能位于 NacosBridge 所在命名空间, 只在该命名空间监听 Secret
+func cacheObjects() map[client.Object]cache.ByObject {
+	ns := os.Getenv("POD_NAMESPACE")
+	if ns == "" {
+		return nil
+	}
+	return map[client.Object]cache.ByObject{
+		&corev1.Secret{}: {Namespaces: map[string]cache.Config{ns: {}}},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"nacosbridge/service"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// runMaintenance 切换 Service 的维护模式, 实例在 Nacos 中被禁用但不注销
+//
+//	nacosbridge maintenance [-n namespace] [--zero-weight] service...
+//	nacosbridge maintenance [-n namespace] --off service...
+func runMaintenance(args []string) error {
+	fs := flag.NewFlagSet("maintenance", flag.ExitOnError)
+	namespace := fs.String("n", "default", "namespace of the Services")
+	zeroWeight := fs.Bool("zero-weight", false, "also set the weight of the instances to 0")
+	off := fs.Bool("off", false, "leave maintenance mode and restore the instances")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("at least one Service name is required")
+	}
+
+	mode := service.MAINTENANCE_DISABLE
+	if *zeroWeight {
+		mode = service.MAINTENANCE_ZERO_WEIGHT
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, name := range fs.Args() {
+		svc := &corev1.Service{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: *namespace, Name: name}, svc); err != nil {
+			return fmt.Errorf("failed to get service %s/%s: %v", *namespace, name, err)
+		}
+		patch := client.MergeFrom(svc.DeepCopy())
+		if *off {
+			delete(svc.Annotations, service.REGISTRY_MAINTENANCE)
+			delete(svc.Labels, service.REGISTRY_MAINTENANCE)
+		} else {
+			if svc.Annotations == nil {
+				svc.Annotations = make(map[string]string)
+			}
+			svc.Annotations[service.REGISTRY_MAINTENANCE] = mode
+		}
+		if err := c.Patch(ctx, svc, patch); err != nil {
+			return fmt.Errorf("failed to patch service %s/%s: %v", *namespace, name, err)
+		}
+		if *off {
+			fmt.Printf("%s/%s: maintenance off\n", *namespace, name)
+		} else {
+			fmt.Printf("%s/%s: maintenance %s\n", *namespace, name, mode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"nacosbridge/api/v1alpha1"
+	"nacosbridge/service"
+	"os"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// runMigrateConfig 将带有 nacosbridge.io/config 标签的 ConfigMap 转换为 NacosBridgeConfig
+// 注册中心的用户名和密码写入单独的 Secret
+//
+//	nacosbridge migrate-config [-n namespace] [--name default] [--apply] [configmap]
+func runMigrateConfig(args []string) error {
+	fs := flag.NewFlagSet("migrate-config", flag.ExitOnError)
+	namespace := fs.String("n", "default", "namespace of the config ConfigMap, also used for the credentials Secret which must be the namespace NacosBridge runs in")
+	name := fs.String("name", "default", "name of the NacosBridgeConfig")
+	apply := fs.Bool("apply", false, "create or update the resources instead of printing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	configmap, err := findConfigMap(ctx, c, *namespace, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	content, err := service.ConfigMapContent(configmap)
+	if err != nil {
+		return fmt.Errorf("invalid config in configmap %s/%s: %v", configmap.Namespace, configmap.Name, err)
+	}
+	config := &service.Config{}
+	if err := config.Load(content); err != nil {
+		return fmt.Errorf("invalid config in configmap %s/%s: %v", configmap.Namespace, configmap.Name, err)
+	}
+	spec, credentials, err := service.BridgeConfigSpec(config)
+	if err != nil {
+		return err
+	}
+
+	objects := make([]client.Object, 0)
+	if len(credentials) > 0 {
+		secret := &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: *name + "-credentials", Namespace: *namespace},
+			StringData: make(map[string]string),
+		}
+		for i, r := range spec.Registries {
+			cred, ok := credentials[r.Name]
+			if !ok {
+				continue
+			}
+			ref := &v1alpha1.CredentialsRef{Name: secret.Name, UsernameKey: "username", PasswordKey: "password"}
+			if len(credentials) > 1 {
+				ref.UsernameKey = r.Name + "-username"
+				ref.PasswordKey = r.Name + "-password"
+			}
+			secret.StringData[ref.UsernameKey] = cred.Username
+			secret.StringData[ref.PasswordKey] = cred.Password
+			spec.Registries[i].CredentialsRef = ref
+		}
+		objects = append(objects, secret)
+	}
+	objects = append(objects, &v1alpha1.NacosBridgeConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1alpha1.GroupVersion.String(), Kind: "NacosBridgeConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: *name},
+		Spec:       spec,
+	})
+
+	if !*apply {
+		docs := make([]string, 0, len(objects))
+		for _, obj := range objects {
+			out, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, string(out))
+		}
+		fmt.Print(strings.Join(docs, "---\n"))
+		return nil
+	}
+
+	for _, obj := range objects {
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		if err := createOrUpdate(ctx, c, obj); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %v", kind, obj.GetName(), err)
+		}
+		fmt.Printf("%s %s applied\n", kind, client.ObjectKeyFromObject(obj))
+	}
+	fmt.Printf("the ConfigMap %s/%s is no longer used and can be deleted\n", configmap.Namespace, configmap.Name)
+	return nil
+}
+
+// findConfigMap 查找配置 ConfigMap, 未指定名称时要求命名空间中只有一个带有配置标签的 ConfigMap
+func findConfigMap(ctx context.Context, c client.Client, namespace, name string) (*corev1.ConfigMap, error) {
+	if name != "" {
+		configmap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, configmap); err != nil {
+			return nil, fmt.Errorf("failed to get configmap %s/%s: %v", namespace, name, err)
+		}
+		return configmap, nil
+	}
+
+	configmaps := &corev1.ConfigMapList{}
+	if err := c.List(ctx, configmaps, client.InNamespace(namespace), client.MatchingLabels{service.REGISTRY_CONFIG: "true"}); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps in %s: %v", namespace, err)
+	}
+	switch len(configmaps.Items) {
+	case 0:
+		return nil, fmt.Errorf("no configmap labeled %s=true in %s", service.REGISTRY_CONFIG, namespace)
+	case 1:
+		return &configmaps.Items[0], nil
+	}
+	names := make([]string, 0, len(configmaps.Items))
+	for _, item := range configmaps.Items {
+		names = append(names, item.Name)
+	}
+	slices.Sort(names)
+	fmt.Fprintf(os.Stderr, "multiple config configmaps found: %s\n", strings.Join(names, ", "))
+	return nil, fmt.Errorf("specify the configmap to migrate")
+}
+
+func createOrUpdate(ctx context.Context, c client.Client, obj client.Object) error {
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, obj)
+		}
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
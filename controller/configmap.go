@@ -19,6 +19,7 @@ type ConfigMap struct {
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=*
 // +kubebuilder:rbac:groups=core,resources=configmaps/status,verbs=*
 // +kubebuilder:rbac:groups=core,resources=configmaps/finalizers,verbs=*
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 func (c *ConfigMap) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
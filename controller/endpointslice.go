@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"context"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type EndpointSlice struct {
+	Client  client.Client
+	Handler cache.ResourceEventHandler
+}
+
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+
+func (e *EndpointSlice) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	endpointSlice := &discoveryv1.EndpointSlice{}
+	if err := e.Client.Get(ctx, req.NamespacedName, endpointSlice); err != nil {
+		if apierrors.IsNotFound(err) {
+			endpointSlice.Name = req.NamespacedName.Name
+			endpointSlice.Namespace = req.NamespacedName.Namespace
+			e.Handler.OnDelete(endpointSlice)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	e.Handler.OnAdd(endpointSlice, false)
+	return ctrl.Result{}, nil
+}
+
+func (e *EndpointSlice) SetupWithManager(mgr ctrl.Manager) error {
+	e.Client = mgr.GetClient()
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&discoveryv1.EndpointSlice{}).
+		Complete(e)
+}
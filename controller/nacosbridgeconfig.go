@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"nacosbridge/api/v1alpha1"
+	"nacosbridge/service"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type NacosBridgeConfig struct {
+	Client client.Client
+	// 直接读取 API Server, 避免缓存集群中所有 Secret 的内容
+	APIReader client.Reader
+	Handler   cache.ResourceEventHandler
+}
+
+// +kubebuilder:rbac:groups=nacosbridge.io,resources=nacosbridgeconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=nacosbridge.io,resources=nacosbridgeconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch,namespace=system
+
+func (n *NacosBridgeConfig) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	config := &v1alpha1.NacosBridgeConfig{}
+	if err := n.Client.Get(ctx, req.NamespacedName, config); err != nil {
+		if apierrors.IsNotFound(err) {
+			config.Name = req.NamespacedName.Name
+			n.Handler.OnDelete(&service.BridgeConfig{NacosBridgeConfig: config})
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	bc := &service.BridgeConfig{
+		NacosBridgeConfig: config,
+		Credentials:       make(map[string]service.Credentials),
+	}
+	for _, r := range config.Spec.Registries {
+		if r.CredentialsRef == nil {
+			continue
+		}
+		cred, err := n.credentials(ctx, r.CredentialsRef)
+		if err != nil {
+			bc.CredentialsErr = fmt.Errorf("registry %s: %v", r.Name, err)
+			break
+		}
+		bc.Credentials[r.Name] = cred
+	}
+	n.Handler.OnAdd(bc, false)
+	return ctrl.Result{}, nil
+}
+
+// credentials 从桥接所在命名空间的 Secret 读取注册中心的用户名和密码
+func (n *NacosBridgeConfig) credentials(ctx context.Context, ref *v1alpha1.CredentialsRef) (service.Credentials, error) {
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: os.Getenv("POD_NAMESPACE"), Name: ref.Name}
+	if err := n.APIReader.Get(ctx, key, secret); err != nil {
+		return service.Credentials{}, fmt.Errorf("failed to get secret %s: %v", key, err)
+	}
+
+	usernameKey, passwordKey := ref.UsernameKey, ref.PasswordKey
+	if usernameKey == "" {
+		usernameKey = "username"
+	}
+	if passwordKey == "" {
+		passwordKey = "password"
+	}
+	username, ok := secret.Data[usernameKey]
+	if !ok {
+		return service.Credentials{}, fmt.Errorf("key %s not found in secret %s", usernameKey, key)
+	}
+	password, ok := secret.Data[passwordKey]
+	if !ok {
+		return service.Credentials{}, fmt.Errorf("key %s not found in secret %s", passwordKey, key)
+	}
+	return service.Credentials{Username: string(username), Password: string(password)}, nil
+}
+
+// configsForSecret 返回引用了该 Secret 的 NacosBridgeConfig
+func (n *NacosBridgeConfig) configsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != os.Getenv("POD_NAMESPACE") {
+		return nil
+	}
+	configs := &v1alpha1.NacosBridgeConfigList{}
+	if err := n.Client.List(ctx, configs); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, config := range configs.Items {
+		for _, r := range config.Spec.Registries {
+			if r.CredentialsRef != nil && r.CredentialsRef.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: config.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+func (n *NacosBridgeConfig) SetupWithManager(mgr ctrl.Manager) error {
+	n.Client = mgr.GetClient()
+	n.APIReader = mgr.GetAPIReader()
+	// 状态回写不会改变 generation, 避免自身的状态更新触发重建
+	// Secret 只监听元数据, 变化时按引用关系重新协调, 内容在协调时通过 APIReader 直接读取
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.NacosBridgeConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(n.configsForSecret), builder.OnlyMetadata).
+		Complete(n)
+}
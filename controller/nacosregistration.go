@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+
+	"nacosbridge/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+type NacosRegistration struct {
+	Client  client.Client
+	Handler cache.ResourceEventHandler
+}
+
+// +kubebuilder:rbac:groups=nacosbridge.io,resources=nacosregistrations,verbs=get;list;watch
+// +kubebuilder:rbac:groups=nacosbridge.io,resources=nacosregistrations/status,verbs=get;update;patch
+
+func (n *NacosRegistration) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	registration := &v1alpha1.NacosRegistration{}
+	if err := n.Client.Get(ctx, req.NamespacedName, registration); err != nil {
+		if apierrors.IsNotFound(err) {
+			registration.Name = req.NamespacedName.Name
+			registration.Namespace = req.NamespacedName.Namespace
+			n.Handler.OnDelete(registration)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	n.Handler.OnAdd(registration, false)
+	return ctrl.Result{}, nil
+}
+
+func (n *NacosRegistration) SetupWithManager(mgr ctrl.Manager) error {
+	n.Client = mgr.GetClient()
+	// 状态回写不会改变 generation, 避免自身的状态更新触发重建
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.NacosRegistration{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(n)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type Namespace struct {
+	Client  client.Client
+	Handler cache.ResourceEventHandler
+}
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+func (n *Namespace) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	namespace := &corev1.Namespace{}
+	if err := n.Client.Get(ctx, req.NamespacedName, namespace); err != nil {
+		if apierrors.IsNotFound(err) {
+			namespace.Name = req.NamespacedName.Name
+			n.Handler.OnDelete(namespace)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	n.Handler.OnAdd(namespace, false)
+	return ctrl.Result{}, nil
+}
+
+func (n *Namespace) SetupWithManager(mgr ctrl.Manager) error {
+	n.Client = mgr.GetClient()
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}).
+		Complete(n)
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"nacosbridge/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BridgeConfig NacosBridgeConfig 资源以及从 Secret 读取的凭据
+type BridgeConfig struct {
+	*v1alpha1.NacosBridgeConfig
+
+	// 注册中心凭据, 键为注册中心名称
+	Credentials map[string]Credentials
+	// 读取凭据失败的原因
+	CredentialsErr error
+}
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// LoadBridgeConfig 从 NacosBridgeConfig 生成配置
+func (c *Config) LoadBridgeConfig(bc *BridgeConfig) error {
+	c.initialize.Do(c.init)
+	if bc.CredentialsErr != nil {
+		return bc.CredentialsErr
+	}
+
+	spec := bc.Spec
+	for _, r := range spec.Registries {
+		c.ServiceConfig[r.Name+".address"] = r.Address
+		if r.Port != 0 {
+			c.ServiceConfig[r.Name+".port"] = strconv.Itoa(int(r.Port))
+		}
+		if cred, ok := bc.Credentials[r.Name]; ok {
+			c.ServiceConfig[r.Name+".username"] = cred.Username
+			c.ServiceConfig[r.Name+".password"] = cred.Password
+		}
+		if len(r.WatchNamespaces) > 0 {
+			c.WatchNamespace[r.Name] = strings.Join(r.WatchNamespaces, ",")
+		}
+	}
+
+	c.ClusterName = spec.ClusterName
+	c.ClusterDomain = spec.ClusterDomain
+	c.AddressType = spec.AddressType
+	c.AddressTemplate = spec.AddressTemplate
+	c.NodeSelector = spec.Nodes.Selector
+	c.NodeAddressType = spec.Nodes.AddressType
+	c.IncludeNotReadyNodes = spec.Nodes.IncludeNotReady
+	c.IncludeUnschedulableNodes = spec.Nodes.IncludeUnschedulable
+	c.IncludeTaintedNodes = spec.Nodes.IncludeTainted
+	if spec.Nodes.DrainGracePeriod != nil {
+		c.NodeDrainGracePeriod = spec.Nodes.DrainGracePeriod.Duration.String()
+	}
+	c.TopologyCluster = spec.Nodes.TopologyCluster
+	c.IPFamily = spec.IPFamily
+	c.ServiceNameTemplate = spec.ServiceNameTemplate
+	c.GroupTemplate = spec.GroupTemplate
+	c.MetadataTemplates = maps.Clone(spec.MetadataTemplates)
+	c.Metadata = maps.Clone(spec.Metadata)
+	c.AutoMetadata = slices.Clone(spec.AutoMetadata)
+
+	c.NamespaceMapping = NamespaceMapping{
+		Identity: spec.NamespaceMapping.Identity,
+		Default:  spec.NamespaceMapping.Default,
+	}
+	for _, entry := range spec.NamespaceMapping.Namespaces {
+		c.NamespaceMapping.Namespaces = append(c.NamespaceMapping.Namespaces, NamespaceMappingEntry{
+			Namespace:      entry.Namespace,
+			NacosNamespace: entry.NacosNamespace,
+		})
+	}
+	for _, r := range spec.Rules {
+		c.Rules = append(c.Rules, Rule{
+			Name:              r.Name,
+			Namespaces:        slices.Clone(r.Namespaces),
+			NamespaceSelector: r.NamespaceSelector,
+			Selector:          r.Selector,
+			ServiceType:       r.ServiceType,
+			OpenPort:          r.OpenPort,
+			ServiceName:       r.ServiceName,
+			Namespace:         r.Namespace,
+			Group:             r.Group,
+		})
+	}
+	for _, p := range spec.Policies {
+		c.Policies = append(c.Policies, Policy{Name: p.Name, Expression: p.Expression, Message: p.Message})
+	}
+	return c.complete()
+}
+
+// BridgeConfigSpec 将 ConfigMap 中的配置转换为 NacosBridgeConfig, 用于迁移
+// 凭据不会写入 spec, 返回的凭据需要保存到 Secret 中
+func BridgeConfigSpec(c *Config) (v1alpha1.NacosBridgeConfigSpec, map[string]Credentials, error) {
+	spec := v1alpha1.NacosBridgeConfigSpec{
+		ClusterName:     c.ClusterName,
+		ClusterDomain:   c.ClusterDomain,
+		AddressType:     c.AddressType,
+		AddressTemplate: c.AddressTemplate,
+		Nodes: v1alpha1.NodeSpec{
+			Selector:             c.NodeSelector,
+			AddressType:          c.NodeAddressType,
+			IncludeNotReady:      c.IncludeNotReadyNodes,
+			IncludeUnschedulable: c.IncludeUnschedulableNodes,
+			IncludeTainted:       c.IncludeTaintedNodes,
+			TopologyCluster:      c.TopologyCluster,
+		},
+		IPFamily:            c.IPFamily,
+		ServiceNameTemplate: c.ServiceNameTemplate,
+		GroupTemplate:       c.GroupTemplate,
+		MetadataTemplates:   maps.Clone(c.MetadataTemplates),
+		Metadata:            maps.Clone(c.Metadata),
+		AutoMetadata:        slices.Clone(c.AutoMetadata),
+		NamespaceMapping: v1alpha1.NamespaceMappingSpec{
+			Identity: c.NamespaceMapping.Identity,
+			Default:  c.NamespaceMapping.Default,
+		},
+	}
+	if c.nodeDrainGracePeriod > 0 {
+		spec.Nodes.DrainGracePeriod = &metav1.Duration{Duration: c.nodeDrainGracePeriod}
+	}
+
+	credentials := make(map[string]Credentials)
+	for _, name := range []string{(&Nacos{}).Name()} {
+		settings := GeneratePrefixConfig(name, c.ServiceConfig)
+		if len(settings) == 0 {
+			continue
+		}
+		registry := v1alpha1.RegistrySpec{
+			Name:    name,
+			Address: settings["address"],
+		}
+		if p, ok := settings["port"]; ok {
+			port, err := strconv.Atoi(p)
+			if err != nil {
+				return spec, nil, fmt.Errorf("invalid %s port: %v", name, err)
+			}
+			registry.Port = int32(port)
+		}
+		if settings["username"] != "" || settings["password"] != "" {
+			credentials[name] = Credentials{Username: settings["username"], Password: settings["password"]}
+		}
+		if ns := c.WatchNamespace[name]; ns != "" {
+			registry.WatchNamespaces = SplitList(ns)
+		}
+		spec.Registries = append(spec.Registries, registry)
+	}
+
+	for _, entry := range c.NamespaceMapping.Namespaces {
+		spec.NamespaceMapping.Namespaces = append(spec.NamespaceMapping.Namespaces, v1alpha1.NamespaceMappingEntry{
+			Namespace:      entry.Namespace,
+			NacosNamespace: entry.NacosNamespace,
+		})
+	}
+	for _, r := range c.Rules {
+		spec.Rules = append(spec.Rules, v1alpha1.RuleSpec{
+			Name:              r.Name,
+			Namespaces:        slices.Clone(r.Namespaces),
+			NamespaceSelector: r.NamespaceSelector,
+			Selector:          r.Selector,
+			ServiceType:       r.ServiceType,
+			OpenPort:          r.OpenPort,
+			ServiceName:       r.ServiceName,
+			Namespace:         r.Namespace,
+			Group:             r.Group,
+		})
+	}
+	for _, p := range c.Policies {
+		spec.Policies = append(spec.Policies, v1alpha1.PolicySpec{Name: p.Name, Expression: p.Expression, Message: p.Message})
+	}
+	return spec, credentials, nil
+}
+
+// bridgeConfigStatusUpdate 回写 NacosBridgeConfig 的状态, registries 为空时保留原有的注册中心状态
+// 同步结果不变时同步时间最多每 interval 更新一次, 避免每次重建都写入状态
+func bridgeConfigStatusUpdate(bc *BridgeConfig, condition metav1.Condition, registries []v1alpha1.RegistryStatus, interval time.Duration) StatusUpdate {
+	generation := bc.Generation
+	mutator := func(obj client.Object) client.Object {
+		cp := obj.(*v1alpha1.NacosBridgeConfig).DeepCopy()
+		now := metav1.Now()
+		old := meta.FindStatusCondition(cp.Status.Conditions, condition.Type)
+		changed := old == nil || old.Status != condition.Status || old.Reason != condition.Reason ||
+			old.Message != condition.Message || cp.Status.ObservedGeneration != generation
+		condition.ObservedGeneration = generation
+		meta.SetStatusCondition(&cp.Status.Conditions, condition)
+		cp.Status.ObservedGeneration = generation
+		if registries != nil {
+			statuses := slices.Clone(registries)
+			for i, rs := range statuses {
+				if rs.LastSyncTime == nil {
+					continue
+				}
+				for _, last := range cp.Status.Registries {
+					if last.Name == rs.Name {
+						unchanged := last.Connected == rs.Connected && last.Message == rs.Message
+						statuses[i].LastSyncTime = syncTime(last.LastSyncTime, rs.LastSyncTime, unchanged, interval)
+					}
+				}
+			}
+			cp.Status.Registries = statuses
+		}
+		if condition.Status == metav1.ConditionTrue {
+			cp.Status.LastSyncTime = syncTime(cp.Status.LastSyncTime, &now, !changed, interval)
+		}
+		return cp
+	}
+	return NewStatusUpdate(bc.Name, "", &v1alpha1.NacosBridgeConfig{}, StatusMutatorFunc(mutator))
+}
+
+// syncTime 同步结果不变且上一次同步时间未超过 interval 时保留上一次的时间, interval 为 0 时只在结果变化时更新
+func syncTime(last, now *metav1.Time, unchanged bool, interval time.Duration) *metav1.Time {
+	if last != nil && unchanged && (interval <= 0 || now.Sub(last.Time) < interval) {
+		return last
+	}
+	return now
+}
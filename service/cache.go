@@ -2,8 +2,12 @@ package service
 
 import (
 	"sync"
+	"time"
+
+	"nacosbridge/api/v1alpha1"
 
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -14,12 +18,26 @@ type Cache struct {
 	configmaps map[types.NamespacedName]*corev1.ConfigMap
 	services   map[types.NamespacedName]*corev1.Service
 	nodes      map[types.NamespacedName]*corev1.Node
+	namespaces map[types.NamespacedName]*corev1.Namespace
+
+	endpointSlices map[types.NamespacedName]*discoveryv1.EndpointSlice
+
+	// 首次发现节点下线的时间
+	nodeDraining map[string]time.Time
+
+	bridgeConfigs map[string]*BridgeConfig
+	registrations map[types.NamespacedName]*v1alpha1.NacosRegistration
 }
 
 func (c *Cache) init() {
 	c.configmaps = make(map[types.NamespacedName]*corev1.ConfigMap)
 	c.services = make(map[types.NamespacedName]*corev1.Service)
 	c.nodes = make(map[types.NamespacedName]*corev1.Node)
+	c.namespaces = make(map[types.NamespacedName]*corev1.Namespace)
+	c.endpointSlices = make(map[types.NamespacedName]*discoveryv1.EndpointSlice)
+	c.nodeDraining = make(map[string]time.Time)
+	c.bridgeConfigs = make(map[string]*BridgeConfig)
+	c.registrations = make(map[types.NamespacedName]*v1alpha1.NacosRegistration)
 }
 
 func (c *Cache) Insert(obj interface{}) bool {
@@ -36,6 +54,22 @@ func (c *Cache) Insert(obj interface{}) bool {
 		c.services[NamespacedName(o)] = o
 	case *corev1.Node:
 		c.nodes[NamespacedName(o)] = o
+		if !IsNodeDraining(o) {
+			delete(c.nodeDraining, o.Name)
+		} else if _, ok := c.nodeDraining[o.Name]; !ok {
+			c.nodeDraining[o.Name] = time.Now()
+		}
+	case *corev1.Namespace:
+		c.namespaces[NamespacedName(o)] = o
+	case *discoveryv1.EndpointSlice:
+		if o.Labels == nil || o.Labels[discoveryv1.LabelServiceName] == "" {
+			return false
+		}
+		c.endpointSlices[NamespacedName(o)] = o
+	case *BridgeConfig:
+		c.bridgeConfigs[o.Name] = o
+	case *v1alpha1.NacosRegistration:
+		c.registrations[NamespacedName(o)] = o
 	default:
 		return false
 	}
@@ -59,12 +93,83 @@ func (c *Cache) Delete(obj interface{}) bool {
 	case *corev1.Node:
 		if _, ok := c.nodes[NamespacedName(o)]; ok {
 			delete(c.nodes, NamespacedName(o))
+			delete(c.nodeDraining, o.Name)
+			return true
+		}
+	case *corev1.Namespace:
+		if _, ok := c.namespaces[NamespacedName(o)]; ok {
+			delete(c.namespaces, NamespacedName(o))
+			return true
+		}
+	case *discoveryv1.EndpointSlice:
+		if _, ok := c.endpointSlices[NamespacedName(o)]; ok {
+			delete(c.endpointSlices, NamespacedName(o))
+			return true
+		}
+	case *BridgeConfig:
+		if _, ok := c.bridgeConfigs[o.Name]; ok {
+			delete(c.bridgeConfigs, o.Name)
+			return true
+		}
+	case *v1alpha1.NacosRegistration:
+		if _, ok := c.registrations[NamespacedName(o)]; ok {
+			delete(c.registrations, NamespacedName(o))
 			return true
 		}
 	}
 	return false
 }
 
+// Namespace 返回缓存中的命名空间, 不存在时返回 nil
+func (c *Cache) Namespace(name string) *corev1.Namespace {
+	c.initialize.Do(c.init)
+	return c.namespaces[types.NamespacedName{Name: name}]
+}
+
+// NodeDrainingSince 返回节点开始下线的时间
+func (c *Cache) NodeDrainingSince(node *corev1.Node) time.Time {
+	c.initialize.Do(c.init)
+
+	firstSeen, ok := c.nodeDraining[node.Name]
+	if !ok {
+		firstSeen = time.Now()
+	}
+	return nodeDrainingSince(node, firstSeen)
+}
+
+// NextDrainDeadline 返回距离最近一个下线节点宽限期结束的时间, 没有时返回 0
+func (c *Cache) NextDrainDeadline(grace time.Duration) time.Duration {
+	c.initialize.Do(c.init)
+
+	if grace <= 0 {
+		return 0
+	}
+	next := time.Duration(0)
+	now := time.Now()
+	for _, node := range c.nodes {
+		if _, ok := c.nodeDraining[node.Name]; !ok {
+			continue
+		}
+		if d := c.NodeDrainingSince(node).Add(grace).Sub(now); d > 0 && (next == 0 || d < next) {
+			next = d
+		}
+	}
+	return next
+}
+
+// EndpointSlicesFor 返回属于指定服务的 EndpointSlice
+func (c *Cache) EndpointSlicesFor(svc *corev1.Service) []*discoveryv1.EndpointSlice {
+	c.initialize.Do(c.init)
+
+	slices := make([]*discoveryv1.EndpointSlice, 0)
+	for _, es := range c.endpointSlices {
+		if es.Namespace == svc.Namespace && es.Labels[discoveryv1.LabelServiceName] == svc.Name {
+			slices = append(slices, es)
+		}
+	}
+	return slices
+}
+
 func NamespacedName(obj client.Object) types.NamespacedName {
 	return types.NamespacedName{
 		Namespace: obj.GetNamespace(),
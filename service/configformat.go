@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// config data key in JSON format
+	CONFIG_KEY_JSON = "config.json"
+
+	// config data key in YAML format
+	CONFIG_KEY_YAML = "config.yaml"
+
+	// prefix of the environment variables overriding flags and service_config, e.g. NACOSBRIDGE_NACOS_ADDRESS
+	CONFIG_ENV_PREFIX = "NACOSBRIDGE_"
+)
+
+// HasConfig 判断 ConfigMap 中是否有配置
+func HasConfig(cm *corev1.ConfigMap) bool {
+	_, hasJSON := cm.Data[CONFIG_KEY_JSON]
+	_, hasYAML := cm.Data[CONFIG_KEY_YAML]
+	return hasJSON || hasYAML
+}
+
+// ConfigMapContent 读取 ConfigMap 中的配置, 支持 config.json 和 config.yaml, 统一返回 JSON
+func ConfigMapContent(cm *corev1.ConfigMap) (string, error) {
+	content, hasJSON := cm.Data[CONFIG_KEY_JSON]
+	yamlContent, hasYAML := cm.Data[CONFIG_KEY_YAML]
+	switch {
+	case hasJSON && hasYAML:
+		return "", fmt.Errorf("only one of %s and %s can be set", CONFIG_KEY_JSON, CONFIG_KEY_YAML)
+	case hasYAML:
+		return yamlToJSON(yamlContent)
+	}
+	return content, nil
+}
+
+// ReadConfigFile 读取配置文件, .yaml 和 .yml 文件按 YAML 解析, 其余按 JSON 解析
+func ReadConfigFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yamlToJSON(string(content))
+	}
+	return string(content), nil
+}
+
+func yamlToJSON(content string) (string, error) {
+	out, err := yaml.YAMLToJSONStrict([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("invalid YAML: %v", err)
+	}
+	return string(out), nil
+}
+
+// configEnvName 返回覆盖 service_config 的环境变量名, 例如 nacos.address -> NACOSBRIDGE_NACOS_ADDRESS
+func configEnvName(registry, key string) string {
+	return CONFIG_ENV_PREFIX + strings.ToUpper(registry+"_"+key)
+}
+
+// envOverrides 读取覆盖 service_config 的环境变量, 环境变量优先于所有配置来源
+func envOverrides() map[string]string {
+	overrides := make(map[string]string)
+	for registry, keys := range registrySettings {
+		for _, key := range keys {
+			if value := os.Getenv(configEnvName(registry, key)); value != "" {
+				overrides[registry+"."+key] = value
+			}
+		}
+	}
+	return overrides
+}
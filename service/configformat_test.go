@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestConfigMapContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "json", data: map[string]string{CONFIG_KEY_JSON: `{"cluster_domain":"a"}`}, want: `{"cluster_domain":"a"}`},
+		{name: "yaml", data: map[string]string{CONFIG_KEY_YAML: "cluster_domain: a\n"}, want: `{"cluster_domain":"a"}`},
+		{name: "both", data: map[string]string{CONFIG_KEY_JSON: "{}", CONFIG_KEY_YAML: "{}"}, wantErr: true},
+		{name: "duplicate yaml keys", data: map[string]string{CONFIG_KEY_YAML: "a: 1\na: 2\n"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConfigMapContent(&corev1.ConfigMap{Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("content = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("NACOSBRIDGE_NACOS_ADDRESS", "env")
+	content := `{"service_config": {"nacos.address": "file", "nacos.port": "8848"}}`
+
+	// Load 不读取环境变量, 避免 migrate-config 将操作者的环境变量写入生成的资源
+	config := &Config{}
+	if err := config.Load(content); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.ServiceConfig["nacos.address"]; got != "file" {
+		t.Errorf("Load: nacos.address = %q, want file", got)
+	}
+
+	s := NewService(nil, ServerOptions{})
+	s.cache.Insert(configMap("", "config", "", content))
+	config, _, err := s.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.ServiceConfig["nacos.address"]; got != "env" {
+		t.Errorf("loadConfig: nacos.address = %q, want env", got)
+	}
+	if got := config.ServiceConfig["nacos.port"]; got != "8848" {
+		t.Errorf("loadConfig: nacos.port = %q, want 8848", got)
+	}
+}
+
+func TestEnvOverridesRequiredAddress(t *testing.T) {
+	t.Setenv("NACOSBRIDGE_NACOS_ADDRESS", "env")
+	s := NewService(nil, ServerOptions{})
+	s.cache.Insert(configMap("", "config", "", `{}`))
+	if _, _, err := s.loadConfig(); err != nil {
+		t.Errorf("the address from the environment should satisfy validation: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 多个配置 ConfigMap 按以下规则合并:
+//
+// NacosBridge 所在命名空间中的 ConfigMap 为全局配置, 按优先级从高到低合并,
+// 对象按键合并且高优先级的值生效, 数组按优先级顺序拼接
+//
+// 其它命名空间中的 ConfigMap 为团队配置, 只能设置 rules 和 namespace_mapping.namespaces,
+// 且只作用于所在命名空间; 团队配置先于全局配置匹配
+//
+// 优先级由 nacosbridge.io/config-priority 注解设置, 默认为 0, 相同时按命名空间/名称排序
+
+// 团队配置中允许的键
+var teamConfigKeys = []string{"rules", "namespace_mapping"}
+
+// configFragment 单个配置 ConfigMap
+type configFragment struct {
+	configmap *corev1.ConfigMap
+	priority  int
+	raw       map[string]interface{}
+	config    *Config
+}
+
+// newConfigFragment 解析 ConfigMap 中的配置, 全局配置只校验键和类型, 取值在合并后校验
+func newConfigFragment(cm *corev1.ConfigMap, team bool) (*configFragment, error) {
+	errs := make([]string, 0)
+	priority := 0
+	if value, ok := cm.Annotations[REGISTRY_CONFIG_PRIORITY]; ok {
+		p, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: invalid priority %q", REGISTRY_CONFIG_PRIORITY, value))
+		}
+		priority = p
+	}
+
+	content, err := ConfigMapContent(cm)
+	if err != nil {
+		return nil, &ValidationError{Errors: append(errs, err.Error())}
+	}
+	config := &Config{}
+	config.initialize.Do(config.init)
+	decodeErrs, ok := config.decodeStrict(content)
+	errs = append(errs, decodeErrs...)
+	if !ok {
+		return nil, &ValidationError{Errors: errs}
+	}
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return nil, &ValidationError{Errors: append(errs, fmt.Sprintf("config must be a JSON object: %v", err))}
+	}
+
+	if team {
+		errs = append(errs, teamConfigErrors(cm.Namespace, raw, config)...)
+		// 团队规则只匹配所在命名空间
+		for i := range config.Rules {
+			config.Rules[i].Namespaces = []string{cm.Namespace}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+	return &configFragment{configmap: cm, priority: priority, raw: raw, config: config}, nil
+}
+
+// teamConfigErrors 校验团队配置只设置了允许的键且只作用于所在命名空间
+func teamConfigErrors(namespace string, raw map[string]interface{}, config *Config) []string {
+	errs := make([]string, 0)
+	for key, value := range raw {
+		if !slices.Contains(teamConfigKeys, key) {
+			errs = append(errs, fmt.Sprintf("%s: not allowed in a team config, only rules and namespace_mapping.namespaces can be set", key))
+			continue
+		}
+		if mapping, ok := value.(map[string]interface{}); ok && key == "namespace_mapping" {
+			for k := range mapping {
+				if k != "namespaces" {
+					errs = append(errs, fmt.Sprintf("namespace_mapping.%s: not allowed in a team config", k))
+				}
+			}
+		}
+	}
+	for i, r := range config.Rules {
+		for _, ns := range r.Namespaces {
+			if ns != namespace {
+				errs = append(errs, fmt.Sprintf("rules[%d].namespaces: a team config can only match its own namespace %s, got %q", i, namespace, ns))
+			}
+		}
+	}
+	for i, entry := range config.NamespaceMapping.Namespaces {
+		if entry.Namespace != namespace {
+			errs = append(errs, fmt.Sprintf("namespace_mapping.namespaces[%d].namespace: a team config can only map its own namespace %s, got %q", i, namespace, entry.Namespace))
+		}
+	}
+	errs = append(errs, config.validateNamespaceMapping()...)
+	errs = append(errs, config.validateRules()...)
+	slices.Sort(errs)
+	return errs
+}
+
+// sortConfigFragments 按优先级从高到低排序, 相同时按命名空间/名称排序
+func sortConfigFragments(fragments []*configFragment) {
+	slices.SortFunc(fragments, func(a, b *configFragment) int {
+		if c := cmp.Compare(b.priority, a.priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(NamespacedName(a.configmap).String(), NamespacedName(b.configmap).String())
+	})
+}
+
+// mergeConfigJSON 将低优先级的 src 合并到 dst, 对象按键合并, dst 中已有的值生效, 数组拼接在 dst 之后
+func mergeConfigJSON(dst, src map[string]interface{}) {
+	for key, value := range src {
+		existing, ok := dst[key]
+		if !ok {
+			// 复制对象, 避免修改 src
+			if v, isMap := value.(map[string]interface{}); isMap {
+				copied := make(map[string]interface{})
+				mergeConfigJSON(copied, v)
+				value = copied
+			}
+			dst[key] = value
+			continue
+		}
+		switch e := existing.(type) {
+		case map[string]interface{}:
+			if v, ok := value.(map[string]interface{}); ok {
+				mergeConfigJSON(e, v)
+			}
+		case []interface{}:
+			if v, ok := value.([]interface{}); ok {
+				dst[key] = slices.Concat(e, v)
+			}
+		}
+	}
+}
+
+// mergeGlobalConfigs 合并已排序的全局配置, 返回合并后的 config.json
+func mergeGlobalConfigs(fragments []*configFragment) (string, error) {
+	merged := make(map[string]interface{})
+	for _, f := range fragments {
+		mergeConfigJSON(merged, f.raw)
+	}
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// applyTeamConfigs 将已排序的团队配置中的规则和命名空间映射放在全局配置之前
+func (c *Config) applyTeamConfigs(teams []*configFragment) error {
+	if len(teams) == 0 {
+		return nil
+	}
+	rules := make([]Rule, 0)
+	mappings := make([]NamespaceMappingEntry, 0)
+	for _, t := range teams {
+		rules = append(rules, t.config.Rules...)
+		mappings = append(mappings, t.config.NamespaceMapping.Namespaces...)
+	}
+	c.Rules = append(rules, c.Rules...)
+	c.NamespaceMapping.Namespaces = append(mappings, c.NamespaceMapping.Namespaces...)
+	return c.complete()
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func configMap(namespace, name, priority, content string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{REGISTRY_CONFIG: "true"},
+		},
+		Data: map[string]string{CONFIG_KEY_JSON: content},
+	}
+	if priority != "" {
+		cm.Annotations = map[string]string{REGISTRY_CONFIG_PRIORITY: priority}
+	}
+	return cm
+}
+
+func decodeJSON(t *testing.T, content string) map[string]interface{} {
+	t.Helper()
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestMergeConfigJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  string
+		src  string
+		want string
+	}{
+		{
+			name: "objects are merged key by key and dst wins",
+			dst:  `{"service_config": {"nacos.address": "a"}, "metadata": {"env": "prod"}}`,
+			src:  `{"service_config": {"nacos.address": "b", "nacos.port": "8848"}}`,
+			want: `{"service_config": {"nacos.address": "a", "nacos.port": "8848"}, "metadata": {"env": "prod"}}`,
+		},
+		{
+			name: "arrays are concatenated after dst",
+			dst:  `{"rules": [{"name": "high"}]}`,
+			src:  `{"rules": [{"name": "low"}]}`,
+			want: `{"rules": [{"name": "high"}, {"name": "low"}]}`,
+		},
+		{
+			name: "nested objects are merged",
+			dst:  `{"namespace_mapping": {"identity": true}}`,
+			src:  `{"namespace_mapping": {"identity": false, "namespaces": [{"namespace": "a", "nacos_namespace": "b"}]}}`,
+			want: `{"namespace_mapping": {"identity": true, "namespaces": [{"namespace": "a", "nacos_namespace": "b"}]}}`,
+		},
+		{
+			name: "scalars in dst win over objects in src",
+			dst:  `{"metadata": "invalid"}`,
+			src:  `{"metadata": {"env": "prod"}}`,
+			want: `{"metadata": "invalid"}`,
+		},
+		{
+			name: "missing keys are copied",
+			dst:  `{}`,
+			src:  `{"cluster_domain": "cluster.local", "metadata": {"env": "prod"}}`,
+			want: `{"cluster_domain": "cluster.local", "metadata": {"env": "prod"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := decodeJSON(t, tt.dst)
+			mergeConfigJSON(dst, decodeJSON(t, tt.src))
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(dst, want) {
+				t.Errorf("merged = %v, want %v", dst, want)
+			}
+		})
+	}
+}
+
+func TestMergeConfigJSONDoesNotModifySrc(t *testing.T) {
+	src := decodeJSON(t, `{"metadata": {"env": "prod"}}`)
+	dst := make(map[string]interface{})
+	mergeConfigJSON(dst, src)
+	mergeConfigJSON(dst, decodeJSON(t, `{"metadata": {"team": "a"}}`))
+	if want := decodeJSON(t, `{"metadata": {"env": "prod"}}`); !reflect.DeepEqual(src, want) {
+		t.Errorf("src = %v, want %v", src, want)
+	}
+}
+
+func TestSortConfigFragments(t *testing.T) {
+	fragments := []*configFragment{
+		{configmap: configMap("system", "b", "", "{}")},
+		{configmap: configMap("system", "low", "", "{}"), priority: -1},
+		{configmap: configMap("system", "high", "", "{}"), priority: 10},
+		{configmap: configMap("system", "a", "", "{}")},
+		{configmap: configMap("other", "z", "", "{}")},
+	}
+	sortConfigFragments(fragments)
+	got := make([]string, 0, len(fragments))
+	for _, f := range fragments {
+		got = append(got, NamespacedName(f.configmap).String())
+	}
+	want := []string{"system/high", "other/z", "system/a", "system/b", "system/low"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestMergeGlobalConfigsPriority(t *testing.T) {
+	fragments := make([]*configFragment, 0)
+	for _, cm := range []*corev1.ConfigMap{
+		configMap("system", "base", "", `{"service_config": {"nacos.address": "base"}, "rules": [{"name": "base"}]}`),
+		configMap("system", "override", "10", `{"service_config": {"nacos.address": "override"}, "rules": [{"name": "override"}]}`),
+	} {
+		f, err := newConfigFragment(cm, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fragments = append(fragments, f)
+	}
+	sortConfigFragments(fragments)
+	content, err := mergeGlobalConfigs(fragments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"rules": [{"name": "override"}, {"name": "base"}], "service_config": {"nacos.address": "override"}}`
+	if got := decodeJSON(t, content); !reflect.DeepEqual(got, decodeJSON(t, want)) {
+		t.Errorf("merged = %s, want %s", content, want)
+	}
+}
+
+func TestNewConfigFragmentPriority(t *testing.T) {
+	_, err := newConfigFragment(configMap("system", "a", "high", "{}"), false)
+	if err == nil || !strings.Contains(err.Error(), "invalid priority") {
+		t.Errorf("err = %v, want invalid priority", err)
+	}
+}
+
+func TestTeamConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errs    []string
+	}{
+		{
+			name:    "rules and namespace mappings of its own namespace",
+			content: `{"rules": [{"name": "web", "selector": "tier=web"}], "namespace_mapping": {"namespaces": [{"namespace": "team-a", "nacos_namespace": "prod"}]}}`,
+		},
+		{
+			name:    "global settings are rejected",
+			content: `{"service_config": {"nacos.address": "a"}}`,
+			errs:    []string{"service_config: not allowed in a team config"},
+		},
+		{
+			name:    "namespace mapping settings other than namespaces are rejected",
+			content: `{"namespace_mapping": {"identity": true}}`,
+			errs:    []string{"namespace_mapping.identity: not allowed in a team config"},
+		},
+		{
+			name:    "rules of other namespaces are rejected",
+			content: `{"rules": [{"name": "web", "namespaces": ["team-b"]}]}`,
+			errs:    []string{`rules[0].namespaces: a team config can only match its own namespace team-a, got "team-b"`},
+		},
+		{
+			name:    "mappings of other namespaces are rejected",
+			content: `{"namespace_mapping": {"namespaces": [{"namespace": "team-*", "nacos_namespace": "prod"}]}}`,
+			errs:    []string{`namespace_mapping.namespaces[0].namespace: a team config can only map its own namespace team-a, got "team-*"`},
+		},
+		{
+			name:    "invalid rules are rejected",
+			content: `{"rules": [{"name": "web", "selector": "tier in (web"}]}`,
+			errs:    []string{"rules[0].selector:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newConfigFragment(configMap("team-a", "rules", "", tt.content), true)
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, r := range f.config.Rules {
+					if !slices.Equal(r.Namespaces, []string{"team-a"}) {
+						t.Errorf("rule %s namespaces = %v, want [team-a]", r.Name, r.Namespaces)
+					}
+				}
+				return
+			}
+			validationErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("err = %v, want a ValidationError", err)
+			}
+			if len(validationErr.Errors) != len(tt.errs) {
+				t.Fatalf("errors = %q, want %q", validationErr.Errors, tt.errs)
+			}
+			for i, e := range tt.errs {
+				if !strings.HasPrefix(validationErr.Errors[i], e) {
+					t.Errorf("errors[%d] = %q, want prefix %q", i, validationErr.Errors[i], e)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigLegacyConfigMap(t *testing.T) {
+	content := `{"service_config": {"nacos.address": "nacos"}}`
+	s := NewService(nil, ServerOptions{})
+	s.namespace = "nacosbridge"
+	s.cache.Insert(configMap("kube-system", "legacy", "", content))
+
+	config, _, err := s.loadConfig()
+	if err != nil {
+		t.Fatalf("a lone config ConfigMap outside the bridge namespace should be used as the global config: %v", err)
+	}
+	if got := config.ServiceConfig["nacos.address"]; got != "nacos" {
+		t.Errorf("nacos.address = %q, want nacos", got)
+	}
+
+	// 添加全局配置后, 命名空间外的 ConfigMap 成为团队配置
+	s.cache.Insert(configMap("nacosbridge", "global", "", content))
+	_, source, err := s.loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range source.reports {
+		if r.obj.GetName() == "legacy" && r.err == nil {
+			t.Error("expected the ConfigMap outside the bridge namespace to be rejected as a team config")
+		}
+	}
+}
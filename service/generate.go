@@ -1,51 +1,179 @@
 package service
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 )
 
 const (
+	// prefix of all registry keys
+	REGISTRY_PREFIX = "nacosbridge.io/"
+
 	// registry config
 	REGISTRY_CONFIG = "nacosbridge.io/config"
 
+	// registry config merge priority of a config ConfigMap, higher wins
+	REGISTRY_CONFIG_PRIORITY = "nacosbridge.io/config-priority"
+
+	// registry enable all services of a namespace, or opt a service out with "false"
+	REGISTRY_ENABLED = "nacosbridge.io/enabled"
+
 	// registry service name
 	REGISTRY_SERVICE_NAME = "nacosbridge.io/service"
 
 	// registry service namespace
 	REGISTRY_SERVICE_NAMESPACE = "nacosbridge.io/namespace"
 
+	// registry service group
+	REGISTRY_SERVICE_GROUP = "nacosbridge.io/group"
+
+	// registry service name template (annotation)
+	REGISTRY_SERVICE_NAME_TEMPLATE = "nacosbridge.io/service-template"
+
+	// rule applied to the service, written back by earlier versions and now removed (annotation)
+	REGISTRY_EFFECTIVE_RULE = "nacosbridge.io/effective-rule"
+
+	// policy rejections of the service, written back by earlier versions and now removed (annotation)
+	REGISTRY_POLICY_REJECTED = "nacosbridge.io/policy-rejected"
+
 	// registry service type
 	REGISTRY_SERVICE_TYPE = "nacosbridge.io/service-type"
 
 	// registry service cluster to external
 	REGISTRY_SERVICE_EXTERNAL = "nacosbridge.io/external"
 
-	// registry service openport
+	// registry service openport, label or annotation
 	REGISTRY_OPENPORT = "nacosbridge.io/openport"
 
-	// registry service portname
+	// registry service name of a port
+	REGISTRY_PORT_SERVICE = "nacosbridge.io/port-service-"
+
+	// registry nodeport of a port, external services are switched to NodePort
+	REGISTRY_PORT_NODEPORT = "nacosbridge.io/nodeport-"
+
+	// registry gateway service domain
+	REGISTRY_GATEWAY_DOMAIN = "nacosbridge.io/gateway-domain"
+
+	// registry gateway service port
+	REGISTRY_GATEWAY_PORT = "nacosbridge.io/gateway-port-"
+
+	// registry instance weight, a number or "auto"
+	REGISTRY_WEIGHT = "nacosbridge.io/weight"
+
+	// registry instance weight of a port
+	REGISTRY_PORT_WEIGHT = "nacosbridge.io/weight-"
+
+	// registry maintenance mode, disable instances without deregistering ("true"/"zero-weight")
+	REGISTRY_MAINTENANCE = "nacosbridge.io/maintenance"
+
+	// registry nacos cluster of nodeport instances from node topology (zone/region/none)
+	REGISTRY_TOPOLOGY_CLUSTER = "nacosbridge.io/topology-cluster"
+
+	// registry service metadata (annotation)
+	SERVICE_METADATA = "nacosbridge.io/metadata"
+
+	// registry metadata of a port, nacosbridge.io/metadata.port-<portName>.<key> (annotation)
+	SERVICE_PORT_METADATA = "port-"
+
+	// registry cluster service address type
+	REGISTRY_ADDRESS_TYPE = "nacosbridge.io/address-type"
+
+	// registry cluster service address template (annotation)
+	REGISTRY_ADDRESS_TEMPLATE = "nacosbridge.io/address-template"
+
+	// registry nodeport service node label selector (annotation)
+	REGISTRY_NODE_SELECTOR = "nacosbridge.io/node-selector"
+
+	// registry nodeport service node address type (InternalIP/ExternalIP/Hostname)
+	REGISTRY_NODE_ADDRESS_TYPE = "nacosbridge.io/node-address-type"
+
+	// registry service ip family preference (ipv4/ipv6/both)
+	REGISTRY_IP_FAMILY = "nacosbridge.io/ip-family"
+)
+
+// v1 keys, still recognized but deprecated in favor of the v2 keys above.
+const (
+	// Deprecated: use REGISTRY_PORT_SERVICE, or REGISTRY_PORT_NODEPORT for the nodeport of an external service.
 	REGISTRY_PORTNAME = "nacosbridge.io/portname-"
 
-	// registry service domin
+	// Deprecated: use REGISTRY_GATEWAY_DOMAIN.
 	REGISTRY_SERVICE_DOMIN = "nacosbridge.io/service-domin"
 
-	// registry service domin port
+	// Deprecated: use REGISTRY_GATEWAY_PORT.
 	REGISTRY_SERVICE_DOMIN_PORT = "nacosbridge.io/service-domain-"
 
-	// registry service matedata
+	// Deprecated: use SERVICE_METADATA.
 	SERVICE_MATEDATA = "nacosbridge.io/matedata"
 )
 
+const (
+	// <svc>.<ns>.svc.<cluster domain>
+	ADDRESS_TYPE_FQDN = "fqdn"
+
+	// <svc>.<ns>
+	ADDRESS_TYPE_SHORT = "short"
+
+	// Spec.ClusterIPs
+	ADDRESS_TYPE_CLUSTERIP = "clusterip"
+
+	// Go template
+	ADDRESS_TYPE_TEMPLATE = "template"
+
+	DEFAULT_CLUSTER_DOMAIN = "cluster.local"
+
+	// instance metadata key of the chosen address type
+	METADATA_ADDRESS_TYPE = "address-type"
+)
+
 type Config struct {
-	WatchNamespace map[string]string `json:"watch_namespace"`
-	ServiceConfig  map[string]string `json:"service_config"`
-	initialize     sync.Once
+	WatchNamespace  map[string]string `json:"watch_namespace"`
+	ServiceConfig   map[string]string `json:"service_config"`
+	ClusterDomain   string            `json:"cluster_domain"`
+	AddressType     string            `json:"address_type"`
+	AddressTemplate string            `json:"address_template"`
+
+	NodeSelector              string `json:"node_selector"`
+	NodeAddressType           string `json:"node_address_type"`
+	IncludeNotReadyNodes      bool   `json:"include_not_ready_nodes"`
+	IncludeUnschedulableNodes bool   `json:"include_unschedulable_nodes"`
+	IncludeTaintedNodes       bool   `json:"include_tainted_nodes"`
+
+	// 下线节点上的实例先禁用, 宽限期结束后注销, 例如 "5m"; 为空时立即注销
+	NodeDrainGracePeriod string `json:"node_drain_grace_period"`
+
+	// NodePort 实例的 Nacos 集群名称来源 (zone/region/none)
+	TopologyCluster string `json:"topology_cluster"`
+
+	IPFamily string `json:"ip_family"`
+
+	ClusterName         string            `json:"cluster_name"`
+	ServiceNameTemplate string            `json:"service_name_template"`
+	GroupTemplate       string            `json:"group_template"`
+	MetadataTemplates   map[string]string `json:"metadata_templates"`
+
+	// 所有实例的静态元数据
+	Metadata map[string]string `json:"metadata"`
+	// 开启的自动元数据键, "*" 表示全部
+	AutoMetadata []string `json:"auto_metadata"`
+
+	NamespaceMapping NamespaceMapping `json:"namespace_mapping"`
+
+	Rules    []Rule   `json:"rules"`
+	Policies []Policy `json:"policies"`
+
+	initialize           sync.Once
+	policies             []policyProgram
+	nodeDrainGracePeriod time.Duration
+	// 覆盖 service_config 的环境变量, 只在桥接运行时设置
+	envOverrides map[string]string
 }
 
 func (c *Config) init() {
@@ -53,141 +181,255 @@ func (c *Config) init() {
 	c.ServiceConfig = make(map[string]string)
 }
 
+// Load 解析并校验 config.json, 未知键、类型错误和无效取值合并为一个 ValidationError 返回
 func (c *Config) Load(content string) error {
 	c.initialize.Do(c.init)
-	return json.Unmarshal([]byte(content), &c)
+	errs, ok := c.decodeStrict(content)
+	if !ok {
+		return &ValidationError{Errors: errs}
+	}
+	var validationErr *ValidationError
+	if errors.As(c.complete(), &validationErr) {
+		errs = append(errs, validationErr.Errors...)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// complete 校验配置, 编译策略并解析需要预处理的配置
+func (c *Config) complete() error {
+	if len(c.envOverrides) > 0 {
+		if c.ServiceConfig == nil {
+			c.ServiceConfig = make(map[string]string)
+		}
+		maps.Copy(c.ServiceConfig, c.envOverrides)
+	}
+	errs := c.validate()
+
+	policies, err := compilePolicies(c.Policies)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("policies: %v", err))
+	}
+	c.policies = policies
+
+	c.nodeDrainGracePeriod = 0
+	if c.NodeDrainGracePeriod != "" {
+		grace, err := time.ParseDuration(c.NodeDrainGracePeriod)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("node_drain_grace_period: invalid duration %q", c.NodeDrainGracePeriod))
+		}
+		c.nodeDrainGracePeriod = grace
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (c *Config) GetClusterDomain() string {
+	if c == nil || c.ClusterDomain == "" {
+		return DEFAULT_CLUSTER_DOMAIN
+	}
+	return c.ClusterDomain
 }
 
 type Service struct {
 	Name     string
+	Group    string
 	Port     int32
 	IP       []string
 	NacosNs  string
 	Metadata map[string]string
+
+	// 实例权重
+	Weight float64
+	// 维护模式下禁用实例, 实例保留注册
+	Disabled bool
+	// Nacos 集群名称, 为空时使用 Nacos 默认集群
+	ClusterName string
+	// 按地址覆盖的实例属性
+	Overrides map[string]InstanceOverride
+
+	// 生成注册信息的 Service 端口, 用于策略校验
+	servicePort corev1.ServicePort
 }
 
-func GenerateServiceInfos(svc *corev1.Service, nodeIps []string, selectNamespace map[string]bool) []Service {
+func GenerateServiceInfos(svc *corev1.Service, cache *Cache, selectNamespace map[string]bool, config *Config) ([]Service, error) {
 	serviceInfos := make([]Service, 0)
 
 	// 检查服务是否应该被处理
-	if svc.Labels == nil {
-		return serviceInfos
-	}
-	if _, ok := svc.Labels[REGISTRY_SERVICE_NAME]; !ok {
-		return serviceInfos
+	if !IsServiceEnabled(svc) {
+		return serviceInfos, nil
 	}
 	if len(selectNamespace) > 0 && !selectNamespace[svc.Namespace] {
-		return serviceInfos
+		return serviceInfos, nil
 	}
 
-	switch svc.Labels[REGISTRY_SERVICE_TYPE] {
+	families, err := IPFamilies(svc, config)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceInfos, err = generateServiceInfos(svc, cache, families, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWeights(svc, cache, families, config, serviceInfos); err != nil {
+		return nil, err
+	}
+	if err := applyMaintenance(svc, serviceInfos); err != nil {
+		return nil, err
+	}
+	return evaluatePolicies(config.policies, svc, cache.Namespace(svc.Namespace), serviceInfos)
+}
+
+func generateServiceInfos(svc *corev1.Service, cache *Cache, families []corev1.IPFamily, config *Config) ([]Service, error) {
+	serviceInfos := make([]Service, 0)
+
+	switch GetSetting(svc, REGISTRY_SERVICE_TYPE) {
 	case "cluster":
-		serviceInfos = append(serviceInfos, generateServiceInfosForCluster(svc)...)
+		return generateServiceInfosForCluster(svc, families, config)
 	case "external":
 		switch svc.Spec.Type {
 		case corev1.ServiceTypeLoadBalancer:
-			if svc.Spec.LoadBalancerIP != "" {
-				serviceInfos = append(serviceInfos, generateServiceInfosForExternal(svc, []string{svc.Spec.LoadBalancerIP}, nil)...)
+			// 负载均衡地址在分配之前为空, 分配后 Service 状态变更会再次触发重建
+			if ips := FilterAddresses(loadBalancerAddresses(svc), families); len(ips) > 0 {
+				return generateServiceInfosForExternal(svc, ips, nil, config)
+			}
+		case corev1.ServiceTypeClusterIP:
+			if ips := FilterAddresses(svc.Spec.ExternalIPs, families); len(ips) > 0 {
+				return generateServiceInfosForExternal(svc, ips, nil, config)
 			}
 		case corev1.ServiceTypeExternalName:
 			if svc.Spec.ExternalName != "" {
-				serviceInfos = append(serviceInfos, generateServiceInfosForExternal(svc, []string{svc.Spec.ExternalName}, nil)...)
+				return generateServiceInfosForExternal(svc, []string{svc.Spec.ExternalName}, nil, config)
 			}
 		case corev1.ServiceTypeNodePort:
-			if len(nodeIps) > 0 {
-				serviceInfos = append(serviceInfos, generateServiceInfosForExternal(svc, nodeIps, func(port corev1.ServicePort) int32 {
+			nodeSelector, err := NewNodeSelector(svc, cache, config)
+			if err != nil {
+				return nil, err
+			}
+			// 宽限期内的下线节点保留注册, 实例被禁用
+			nodeIps := FilterAddresses(nodeSelector.NodeAddresses(cache), families)
+			drainingIps := FilterAddresses(nodeSelector.DrainingAddresses(cache), families)
+			if len(nodeIps) > 0 || len(drainingIps) > 0 {
+				infos, err := generateServiceInfosForExternal(svc, appendUnique(nodeIps, drainingIps...), func(port corev1.ServicePort) int32 {
 					return port.NodePort
-				})...)
+				}, config)
+				if err != nil {
+					return nil, err
+				}
+				for i := range infos {
+					for _, ip := range drainingIps {
+						infos[i].setOverride(ip, func(o *InstanceOverride) {
+							o.Disabled = true
+						})
+					}
+				}
+				if err := applyTopology(svc, cache, nodeSelector, families, config, infos); err != nil {
+					return nil, err
+				}
+				return infos, nil
 			}
 		}
 	case "gateway":
-		serviceInfos = append(serviceInfos, generateServiceInfosForGateway(svc)...)
+		return generateServiceInfosForGateway(svc, config)
 	}
-	return serviceInfos
+	return serviceInfos, nil
 }
 
-func generateServiceInfosForCluster(svc *corev1.Service) []Service {
+func generateServiceInfosForCluster(svc *corev1.Service, families []corev1.IPFamily, config *Config) ([]Service, error) {
 	serviceInfos := make([]Service, 0)
 
-	// 获取基础服务名
-	baseServiceName := svc.Name
-	if customName, ok := svc.Labels[REGISTRY_SERVICE_NAME]; ok {
-		baseServiceName = customName
-	}
+	// 获取开放的端口
+	openPorts := OpenPorts(svc)
+	metadata := ServiceMetadata(svc)
 
-	namespace := ""
-	if customNamespace := svc.Labels[REGISTRY_SERVICE_NAMESPACE]; customNamespace != "" {
-		namespace = customNamespace
-	}
+	for _, port := range svc.Spec.Ports {
 
-	// 获取开放的端口名称
-	openPortName := make(map[string]bool)
-	if ns, ok := svc.Labels[REGISTRY_OPENPORT]; ok {
-		for _, name := range strings.Split(ns, ",") {
-			openPortName[name] = true
+		if !openPorts.Match(port) {
+			continue
+		}
+		infos, err := newServiceInfos(svc, port, metadata, config)
+		if err != nil {
+			return nil, err
+		}
+		addressType, addresses, err := clusterAddresses(svc, port, families, config)
+		if err != nil {
+			return nil, err
+		}
+		for _, serviceInfo := range infos {
+			serviceInfo.Metadata[METADATA_ADDRESS_TYPE] = addressType
+			serviceInfo.IP = addresses
+			serviceInfo.Port = port.Port
+			serviceInfos = append(serviceInfos, serviceInfo)
 		}
 	}
-	metadata := GeneratePrefixConfig(SERVICE_MATEDATA, svc.Annotations)
+	return serviceInfos, nil
+}
 
-	for _, port := range svc.Spec.Ports {
+// clusterAddresses 根据地址策略生成集群服务的注册地址, 服务标签优先于全局配置
+func clusterAddresses(svc *corev1.Service, port corev1.ServicePort, families []corev1.IPFamily, config *Config) (string, []string, error) {
+	addressType := config.AddressType
+	if t := GetSetting(svc, REGISTRY_ADDRESS_TYPE); t != "" {
+		addressType = t
+	}
 
-		if !openPortName[port.Name] {
-			continue
+	switch addressType {
+	case "", ADDRESS_TYPE_FQDN:
+	case ADDRESS_TYPE_SHORT:
+		return addressType, []string{fmt.Sprintf("%s.%s", svc.Name, svc.Namespace)}, nil
+	case ADDRESS_TYPE_CLUSTERIP:
+		ips := appendUnique(nil, svc.Spec.ClusterIPs...)
+		if len(ips) == 0 && svc.Spec.ClusterIP != "" {
+			ips = append(ips, svc.Spec.ClusterIP)
 		}
-		serviceName := baseServiceName
-		if svc.Labels != nil {
-			if customPortName := svc.Labels[REGISTRY_PORTNAME+port.Name]; customPortName != "" {
-				serviceName = customPortName
-			}
+		ips = FilterAddresses(slices.DeleteFunc(ips, func(ip string) bool {
+			return ip == corev1.ClusterIPNone
+		}), families)
+		// headless 服务没有 ClusterIP, 回退到 FQDN
+		if len(ips) > 0 {
+			return addressType, ips, nil
+		}
+	case ADDRESS_TYPE_TEMPLATE:
+		text := config.AddressTemplate
+		if t := GetSetting(svc, REGISTRY_ADDRESS_TEMPLATE); t != "" {
+			text = t
+		}
+		if text == "" {
+			return "", nil, fmt.Errorf("address template is required for address type %s", addressType)
+		}
+		address, err := RenderTemplate(text, NewTemplateData(svc, port, config))
+		if err != nil {
+			return "", nil, err
 		}
-		domin := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
-		portNumber := port.Port
-		serviceInfos = append(serviceInfos, Service{
-			Name:     serviceName,
-			NacosNs:  namespace,
-			IP:       []string{domin},
-			Port:     portNumber,
-			Metadata: metadata,
-		})
-	}
-	return serviceInfos
+		return addressType, []string{address}, nil
+	default:
+		return "", nil, fmt.Errorf("unknown address type %s", addressType)
+	}
+	return ADDRESS_TYPE_FQDN, []string{fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, config.GetClusterDomain())}, nil
 }
 
 // generateServiceInfosForPorts 为指定的IP和端口生成ServiceInfo
-func generateServiceInfosForExternal(svc *corev1.Service, ips []string, portMapper func(corev1.ServicePort) int32) []Service {
+func generateServiceInfosForExternal(svc *corev1.Service, ips []string, portMapper func(corev1.ServicePort) int32, config *Config) ([]Service, error) {
 	serviceInfos := make([]Service, 0)
 
-	// 获取基础服务名
-	baseServiceName := svc.Name
-	if customName, ok := svc.Labels[REGISTRY_SERVICE_NAME]; ok {
-		baseServiceName = customName
-	}
-
-	namespace := ""
-	if customNamespace := svc.Labels[REGISTRY_SERVICE_NAMESPACE]; customNamespace != "" {
-		namespace = customNamespace
-	}
-
-	// 获取开放的端口名称
-	openPortName := make(map[string]bool)
-	if ns, ok := svc.Labels[REGISTRY_OPENPORT]; ok {
-		for _, name := range strings.Split(ns, ",") {
-			openPortName[name] = true
-		}
-	}
+	// 获取开放的端口
+	openPorts := OpenPorts(svc)
 
-	metadata := GeneratePrefixConfig(SERVICE_MATEDATA, svc.Annotations)
+	metadata := ServiceMetadata(svc)
 
 	for _, port := range svc.Spec.Ports {
 
-		if !openPortName[port.Name] {
+		if !openPorts.Match(port) {
 			continue
 		}
-		serviceName := baseServiceName
-		if svc.Labels != nil {
-			if customPortName := svc.Labels[REGISTRY_PORTNAME+port.Name]; customPortName != "" {
-				serviceName = customPortName
-			}
+		infos, err := newServiceInfos(svc, port, metadata, config)
+		if err != nil {
+			return nil, err
 		}
 		// 确定端口号
 		var portNumber int32
@@ -197,56 +439,165 @@ func generateServiceInfosForExternal(svc *corev1.Service, ips []string, portMapp
 			portNumber = port.Port
 		}
 
-		serviceInfos = append(serviceInfos, Service{
-			Name:     serviceName,
-			NacosNs:  namespace,
-			IP:       ips,
-			Port:     portNumber,
-			Metadata: metadata,
-		})
+		for _, serviceInfo := range infos {
+			serviceInfo.IP = ips
+			serviceInfo.Port = portNumber
+			serviceInfos = append(serviceInfos, serviceInfo)
+		}
 	}
 
-	return serviceInfos
+	return serviceInfos, nil
 }
 
-func generateServiceInfosForGateway(svc *corev1.Service) []Service {
+func generateServiceInfosForGateway(svc *corev1.Service, config *Config) ([]Service, error) {
 	serviceInfos := make([]Service, 0)
 
-	// 获取基础服务名
-	baseServiceName := svc.Name
-	if customName, ok := svc.Labels[REGISTRY_SERVICE_NAME]; ok {
-		baseServiceName = customName
+	domin, ok := LookupSetting(svc, REGISTRY_GATEWAY_DOMAIN)
+	if !ok {
+		return serviceInfos, nil
 	}
+	metadata := ServiceMetadata(svc)
 
-	namespace := ""
-	if customNamespace := svc.Labels[REGISTRY_SERVICE_NAMESPACE]; customNamespace != "" {
-		namespace = customNamespace
+	for _, v := range GetSettingsWithPrefix(svc, REGISTRY_GATEWAY_PORT) {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		// 网关端口不对应 Service 端口, 只使用服务级别的名称
+		infos, err := newServiceInfos(svc, corev1.ServicePort{Port: int32(port)}, metadata, config)
+		if err != nil {
+			return nil, err
+		}
+		for _, serviceInfo := range infos {
+			serviceInfo.IP = []string{domin}
+			serviceInfo.Port = int32(port)
+			serviceInfos = append(serviceInfos, serviceInfo)
+		}
 	}
+	return serviceInfos, nil
+}
 
-	domin, ok := svc.Labels[REGISTRY_SERVICE_DOMIN]
-	if !ok {
-		return serviceInfos
+// newServiceInfos 生成端口的服务名、分组、命名空间和元数据, 标签/注解优先于配置中的模板
+// 命名空间和分组可以是逗号分隔的列表, 每个命名空间和分组的组合生成一个注册信息
+func newServiceInfos(svc *corev1.Service, port corev1.ServicePort, metadata map[string]string, config *Config) ([]Service, error) {
+	data := NewTemplateData(svc, port, config)
+
+	// 服务名: 端口配置 > 服务配置 > 服务模板 > 全局模板 > Service 名称
+	nameTemplate := config.ServiceNameTemplate
+	if t := GetSetting(svc, REGISTRY_SERVICE_NAME_TEMPLATE); t != "" {
+		nameTemplate = t
+	}
+	name := svc.Name
+	if custom := GetSetting(svc, REGISTRY_SERVICE_NAME); custom != "" {
+		name = custom
+	} else if nameTemplate != "" {
+		rendered, err := RenderTemplate(nameTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("service name: %v", err)
+		}
+		name = rendered
+	}
+	if port.Name != "" {
+		if custom := GetSetting(svc, REGISTRY_PORT_SERVICE+port.Name); custom != "" {
+			name = custom
+		}
 	}
-	metadata := GeneratePrefixConfig(SERVICE_MATEDATA, svc.Annotations)
 
-	for k, v := range svc.Labels {
-		if strings.HasPrefix(k, REGISTRY_SERVICE_DOMIN_PORT) {
-			port, err := strconv.Atoi(v)
-			if err != nil {
-				continue
-			}
+	group := GetSetting(svc, REGISTRY_SERVICE_GROUP)
+	if group == "" && config.GroupTemplate != "" {
+		rendered, err := RenderTemplate(config.GroupTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("group: %v", err)
+		}
+		group = rendered
+	}
+
+	// 元数据: 自动元数据 > 端口注解 > 服务注解 > 元数据模板 > 静态元数据
+	portMetadata := maps.Clone(config.Metadata)
+	if portMetadata == nil {
+		portMetadata = make(map[string]string)
+	}
+	for k, text := range config.MetadataTemplates {
+		rendered, err := RenderTemplate(text, data)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %v", k, err)
+		}
+		portMetadata[k] = rendered
+	}
+	maps.Copy(portMetadata, metadata)
+	maps.Copy(portMetadata, ServicePortMetadata(svc, port.Name))
+
+	// 命名空间: 服务配置 > 命名空间映射
+	nacosNs := GetSetting(svc, REGISTRY_SERVICE_NAMESPACE)
+	if nacosNs == "" {
+		resolved, err := config.NamespaceMapping.Resolve(svc.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("namespace mapping: %v", err)
+		}
+		nacosNs = resolved
+	}
+	namespaces := SplitList(nacosNs)
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("nacos namespace is required, set %s or namespace_mapping", REGISTRY_SERVICE_NAMESPACE)
+	}
+	groups := SplitList(group)
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+
+	maps.Copy(portMetadata, AutoMetadata(svc, port, config))
+	serviceInfos := make([]Service, 0, len(namespaces)*len(groups))
+	for _, ns := range namespaces {
+		for _, g := range groups {
 			serviceInfos = append(serviceInfos, Service{
-				Name:     baseServiceName,
-				NacosNs:  namespace,
-				IP:       []string{domin},
-				Port:     int32(port),
-				Metadata: metadata,
+				Name:        name,
+				Group:       g,
+				NacosNs:     ns,
+				Metadata:    maps.Clone(portMetadata),
+				servicePort: port,
 			})
 		}
 	}
-	return serviceInfos
+	return serviceInfos, nil
+}
+
+// loadBalancerAddresses 收集 LoadBalancer 服务的对外地址
+// 优先使用 Status.LoadBalancer.Ingress, 兼容已废弃的 Spec.LoadBalancerIP, 并附加 Spec.ExternalIPs
+func loadBalancerAddresses(svc *corev1.Service) []string {
+	ips := make([]string, 0)
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			ips = appendUnique(ips, ingress.IP)
+		} else if ingress.Hostname != "" {
+			ips = appendUnique(ips, ingress.Hostname)
+		}
+	}
+	if svc.Spec.LoadBalancerIP != "" {
+		ips = appendUnique(ips, svc.Spec.LoadBalancerIP)
+	}
+	return appendUnique(ips, svc.Spec.ExternalIPs...)
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if v == "" || slices.Contains(list, v) {
+			continue
+		}
+		list = append(list, v)
+	}
+	return list
+}
+
+// SplitList 解析逗号分隔的列表, 去除空白、空项和重复项
+func SplitList(value string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		list = appendUnique(list, strings.TrimSpace(v))
+	}
+	return list
 }
 
+// GeneratePrefixConfig 返回以 "<prefix>." 开头的键, 键名去掉 "<prefix>.", 例如 nacos.address -> address
 func GeneratePrefixConfig(prefix string, config map[string]string) map[string]string {
 
 	if prefix == "" || config == nil {
@@ -256,7 +607,7 @@ func GeneratePrefixConfig(prefix string, config map[string]string) map[string]st
 	hasPrefix := fmt.Sprintf("%s.", prefix)
 	for k, v := range config {
 		if strings.HasPrefix(k, hasPrefix) {
-			newKey := strings.TrimPrefix(k, prefix)
+			newKey := strings.TrimPrefix(k, hasPrefix)
 			newConfig[newKey] = v
 		}
 	}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewServiceInfosPortMetadata(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user",
+			Namespace: "default",
+			Annotations: map[string]string{
+				REGISTRY_SERVICE_NAMESPACE:               "public",
+				SERVICE_METADATA + ".team":               "backend",
+				SERVICE_METADATA + ".port-grpc.protocol": "grpc",
+				SERVICE_METADATA + ".port-http.protocol": "http",
+				SERVICE_METADATA + ".port-http.context":  "/api",
+			},
+		},
+	}
+	config := &Config{}
+	tests := []struct {
+		port corev1.ServicePort
+		want map[string]string
+	}{
+		{
+			port: corev1.ServicePort{Name: "grpc", Port: 9090, Protocol: corev1.ProtocolTCP},
+			want: map[string]string{"team": "backend", "protocol": "grpc"},
+		},
+		{
+			port: corev1.ServicePort{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP},
+			want: map[string]string{"team": "backend", "protocol": "http", "context": "/api"},
+		},
+	}
+	for _, tt := range tests {
+		infos, err := newServiceInfos(svc, tt.port, ServiceMetadata(svc), config)
+		if err != nil {
+			t.Fatalf("port %s: %v", tt.port.Name, err)
+		}
+		if len(infos) != 1 {
+			t.Fatalf("port %s: expected 1 service, got %d", tt.port.Name, len(infos))
+		}
+		for k, v := range tt.want {
+			if got := infos[0].Metadata[k]; got != v {
+				t.Errorf("port %s: metadata %s = %q, want %q", tt.port.Name, k, got, v)
+			}
+		}
+	}
+}
+
+func TestNewServiceInfosProtocolMetadata(t *testing.T) {
+	appProtocol := "http"
+	http := corev1.ServicePort{Name: "http", Port: 80, AppProtocol: &appProtocol}
+	grpc := corev1.ServicePort{Name: "grpc", Port: 9090, Protocol: corev1.ProtocolUDP}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "user",
+			Namespace:   "default",
+			Annotations: map[string]string{REGISTRY_SERVICE_NAMESPACE: "public"},
+		},
+		Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{http, grpc}},
+	}
+
+	// 不配置 auto_metadata 时也记录端口协议, 不使用无前缀的键
+	tests := []struct {
+		port corev1.ServicePort
+		want map[string]string
+	}{
+		{port: http, want: map[string]string{METADATA_K8S_PROTOCOL: "TCP", METADATA_K8S_APP_PROTOCOL: "http"}},
+		{port: grpc, want: map[string]string{METADATA_K8S_PROTOCOL: "UDP"}},
+	}
+	for _, tt := range tests {
+		infos, err := newServiceInfos(svc, tt.port, ServiceMetadata(svc), &Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{"protocol", "app-protocol", METADATA_K8S_PROTOCOL, METADATA_K8S_APP_PROTOCOL} {
+			if got := infos[0].Metadata[k]; got != tt.want[k] {
+				t.Errorf("port %s: metadata %s = %q, want %q", tt.port.Name, k, got, tt.want[k])
+			}
+		}
+		if _, ok := infos[0].Metadata[METADATA_K8S_SERVICE]; ok {
+			t.Errorf("port %s: metadata %s should require auto_metadata", tt.port.Name, METADATA_K8S_SERVICE)
+		}
+	}
+
+	// 网关端口不对应 Service 端口, 不记录协议
+	infos, err := newServiceInfos(svc, corev1.ServicePort{Port: 8443}, ServiceMetadata(svc), &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := infos[0].Metadata[METADATA_K8S_PROTOCOL]; ok {
+		t.Errorf("gateway port: metadata %s = %q, want unset", METADATA_K8S_PROTOCOL, v)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+)
+
+// InstanceOverride 按地址覆盖的实例属性, NodePort 实例按所在节点设置
+type InstanceOverride struct {
+	Weight      *float64
+	Disabled    bool
+	ClusterName string
+	Metadata    map[string]string
+}
+
+// setOverride 修改指定地址的实例属性
+func (s *Service) setOverride(ip string, fn func(*InstanceOverride)) {
+	if s.Overrides == nil {
+		s.Overrides = make(map[string]InstanceOverride)
+	}
+	override := s.Overrides[ip]
+	fn(&override)
+	s.Overrides[ip] = override
+}
+
+// instanceKey 实例标识 (命名空间/分组/服务名/IP/端口)
+func (s Service) instanceKey(ip string) string {
+	return fmt.Sprintf("%s.%s.%s.%s:%d", s.NacosNs, s.Group, s.Name, ip, s.Port)
+}
+
+// ForAddress 返回单个地址的注册信息, 合并该地址的实例属性
+func (s Service) ForAddress(ip string) Service {
+	instance := s
+	instance.IP = []string{ip}
+	instance.Overrides = nil
+	override, ok := s.Overrides[ip]
+	if !ok {
+		return instance
+	}
+	if override.Weight != nil {
+		instance.Weight = *override.Weight
+	}
+	instance.Disabled = s.Disabled || override.Disabled
+	if override.ClusterName != "" {
+		instance.ClusterName = override.ClusterName
+	}
+	if len(override.Metadata) > 0 {
+		instance.Metadata = maps.Clone(s.Metadata)
+		if instance.Metadata == nil {
+			instance.Metadata = make(map[string]string)
+		}
+		maps.Copy(instance.Metadata, override.Metadata)
+	}
+	return instance
+}
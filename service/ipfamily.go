@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	IP_FAMILY_IPV4 = "ipv4"
+	IP_FAMILY_IPV6 = "ipv6"
+	IP_FAMILY_BOTH = "both"
+)
+
+// IPFamilies 返回服务需要注册的地址族, 按优先级排序
+// 未配置时跟随 Service 的 Spec.IPFamilies, 都没有时注册两个地址族
+func IPFamilies(svc *corev1.Service, config *Config) ([]corev1.IPFamily, error) {
+	preference := config.IPFamily
+	if p := GetSetting(svc, REGISTRY_IP_FAMILY); p != "" {
+		preference = p
+	}
+
+	switch strings.ToLower(preference) {
+	case "":
+		if len(svc.Spec.IPFamilies) > 0 {
+			return svc.Spec.IPFamilies, nil
+		}
+		return []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}, nil
+	case IP_FAMILY_IPV4:
+		return []corev1.IPFamily{corev1.IPv4Protocol}, nil
+	case IP_FAMILY_IPV6:
+		return []corev1.IPFamily{corev1.IPv6Protocol}, nil
+	case IP_FAMILY_BOTH:
+		if len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+			return []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}, nil
+		}
+		return []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}, nil
+	default:
+		return nil, fmt.Errorf("unknown ip family %s", preference)
+	}
+}
+
+// FilterAddresses 过滤掉不需要的地址族并规范化 IP 格式, 主机名原样保留
+// IPv6 地址以不带方括号和 zone 的压缩格式注册, 这是 Nacos 接受的格式
+func FilterAddresses(addresses []string, families []corev1.IPFamily) []string {
+	hosts := make([]string, 0)
+	byFamily := make(map[corev1.IPFamily][]string)
+	for _, address := range addresses {
+		ip, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"))
+		if err != nil {
+			hosts = appendUnique(hosts, address)
+			continue
+		}
+		ip = ip.Unmap().WithZone("")
+		family := corev1.IPv4Protocol
+		if ip.Is6() {
+			family = corev1.IPv6Protocol
+		}
+		byFamily[family] = appendUnique(byFamily[family], ip.String())
+	}
+
+	result := make([]string, 0, len(addresses))
+	for _, family := range families {
+		result = appendUnique(result, byFamily[family]...)
+	}
+	return append(result, hosts...)
+}
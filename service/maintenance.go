@@ -0,0 +1,41 @@
+package service
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// 禁用实例, 保留注册和权重
+	MAINTENANCE_DISABLE = "true"
+
+	// 禁用实例并将权重设置为 0
+	MAINTENANCE_ZERO_WEIGHT = "zero-weight"
+)
+
+// applyMaintenance 维护模式下禁用服务的所有实例, 实例仍保留在 Nacos 中
+// 移除注解后下一次重建会恢复实例的启用状态和权重
+func applyMaintenance(svc *corev1.Service, serviceInfos []Service) error {
+	mode := GetSetting(svc, REGISTRY_MAINTENANCE)
+	switch mode {
+	case "", "false":
+		return nil
+	case MAINTENANCE_DISABLE, MAINTENANCE_ZERO_WEIGHT:
+	default:
+		return fmt.Errorf("invalid maintenance mode %q, must be %s or %s", mode, MAINTENANCE_DISABLE, MAINTENANCE_ZERO_WEIGHT)
+	}
+
+	for i := range serviceInfos {
+		serviceInfos[i].Disabled = true
+		if mode == MAINTENANCE_ZERO_WEIGHT {
+			serviceInfos[i].Weight = 0
+			for ip := range serviceInfos[i].Overrides {
+				serviceInfos[i].setOverride(ip, func(o *InstanceOverride) {
+					o.Weight = nil
+				})
+			}
+		}
+	}
+	return nil
+}
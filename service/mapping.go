@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"path"
+)
+
+// NamespaceMapping Kubernetes 命名空间到 Nacos 命名空间 ID 的映射, 服务未设置 nacosbridge.io/namespace 时使用
+//
+// 优先级: 精确匹配 > 通配符 (按顺序, 第一个匹配生效) > 1:1 映射 > 默认值
+type NamespaceMapping struct {
+	// 使用 Kubernetes 命名空间名称作为 Nacos 命名空间 ID
+	Identity bool `json:"identity"`
+	// 映射表, 命名空间名称支持通配符
+	Namespaces []NamespaceMappingEntry `json:"namespaces"`
+	// 没有匹配时使用的 Nacos 命名空间 ID
+	Default string `json:"default"`
+}
+
+type NamespaceMappingEntry struct {
+	Namespace      string `json:"namespace"`
+	NacosNamespace string `json:"nacos_namespace"`
+}
+
+// Resolve 返回 Kubernetes 命名空间对应的 Nacos 命名空间 ID, 没有匹配时返回空
+func (m *NamespaceMapping) Resolve(namespace string) (string, error) {
+	for _, entry := range m.Namespaces {
+		if entry.Namespace == namespace {
+			return entry.NacosNamespace, nil
+		}
+	}
+	for _, entry := range m.Namespaces {
+		ok, err := path.Match(entry.Namespace, namespace)
+		if err != nil {
+			return "", fmt.Errorf("invalid namespace pattern %q: %v", entry.Namespace, err)
+		}
+		if ok {
+			return entry.NacosNamespace, nil
+		}
+	}
+	if m.Identity {
+		return namespace, nil
+	}
+	return m.Default, nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Version 桥接版本, 构建时通过 ldflags 写入
+var Version = "dev"
+
+const (
+	// enable all automatic metadata keys
+	AUTO_METADATA_ALL = "*"
+
+	// kubernetes cluster name (config cluster_name)
+	METADATA_K8S_CLUSTER = "k8s.cluster"
+
+	// kubernetes namespace of the service
+	METADATA_K8S_NAMESPACE = "k8s.namespace"
+
+	// kubernetes service name
+	METADATA_K8S_SERVICE = "k8s.service"
+
+	// kubernetes service uid
+	METADATA_K8S_UID = "k8s.uid"
+
+	// kubernetes service port name
+	METADATA_K8S_PORT_NAME = "k8s.port-name"
+
+	// kubernetes service port protocol
+	METADATA_K8S_PROTOCOL = "k8s.protocol"
+
+	// kubernetes service port appProtocol
+	METADATA_K8S_APP_PROTOCOL = "k8s.app-protocol"
+
+	// kubernetes service type
+	METADATA_K8S_SERVICE_TYPE = "k8s.service-type"
+
+	// nacosbridge version
+	METADATA_BRIDGE_VERSION = "nacosbridge.version"
+
+	// topology zone of the node, nodeport instances only
+	METADATA_K8S_ZONE = "k8s.zone"
+
+	// topology region of the node, nodeport instances only
+	METADATA_K8S_REGION = "k8s.region"
+)
+
+// 始终记录的端口协议元数据, 不受 auto_metadata 控制
+var portProtocolKeys = []string{METADATA_K8S_PROTOCOL, METADATA_K8S_APP_PROTOCOL}
+
+// AutoMetadata 生成配置中开启的自动元数据, 值为空的键不添加
+// Service 端口的协议始终记录; 网关端口不对应 Service 端口, 不记录协议
+func AutoMetadata(svc *corev1.Service, port corev1.ServicePort, config *Config) map[string]string {
+	metadata := make(map[string]string)
+	servicePort := slices.ContainsFunc(svc.Spec.Ports, func(p corev1.ServicePort) bool {
+		return p.Name == port.Name && p.Port == port.Port
+	})
+
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	appProtocol := ""
+	if port.AppProtocol != nil {
+		appProtocol = *port.AppProtocol
+	}
+	values := map[string]string{
+		METADATA_K8S_CLUSTER:      config.ClusterName,
+		METADATA_K8S_NAMESPACE:    svc.Namespace,
+		METADATA_K8S_SERVICE:      svc.Name,
+		METADATA_K8S_UID:          string(svc.UID),
+		METADATA_K8S_PORT_NAME:    port.Name,
+		METADATA_K8S_PROTOCOL:     string(protocol),
+		METADATA_K8S_APP_PROTOCOL: appProtocol,
+		METADATA_K8S_SERVICE_TYPE: string(svc.Spec.Type),
+		METADATA_BRIDGE_VERSION:   Version,
+	}
+
+	for k, v := range values {
+		always := servicePort && slices.Contains(portProtocolKeys, k)
+		if v == "" || !(always || autoMetadataEnabled(config, k)) {
+			continue
+		}
+		metadata[k] = v
+	}
+	return metadata
+}
+
+func autoMetadataEnabled(config *Config, key string) bool {
+	return slices.Contains(config.AutoMetadata, AUTO_METADATA_ALL) || slices.Contains(config.AutoMetadata, key)
+}
@@ -2,48 +2,108 @@ package service
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/pprof"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// Metrics 提供 Prometheus 指标和 pprof 接口, 地址为 "0" 时不启动
 type Metrics struct {
 	Addr string
+	// pprof 地址, 为空或与 Addr 相同时和指标共用一个端口
+	PprofAddr string
 }
 
-func NewMetrics(addr string) *Metrics {
-	return &Metrics{Addr: addr}
+func NewMetrics(addr, pprofAddr string) *Metrics {
+	if pprofAddr == "" {
+		pprofAddr = addr
+	}
+	return &Metrics{Addr: addr, PprofAddr: pprofAddr}
+}
+
+// NeedLeaderElection 所有副本都提供指标, 不需要选主
+func (c *Metrics) NeedLeaderElection() bool {
+	return false
 }
 
 func (c *Metrics) Start(ctx context.Context) error {
 	l := log.Log.WithName("metrics")
 
-	handler := http.NewServeMux()
-	handler.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
-		ErrorHandling: promhttp.HTTPErrorOnError,
-	}))
-
-	handler.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
-	handler.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	handler.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-	handler.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	handler.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
-	handler.Handle("/debug/pprof/block", pprof.Handler("block"))
-	handler.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
-	handler.Handle("/debug/pprof/heap", pprof.Handler("heap"))
-	handler.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
-
-	srv := &http.Server{Addr: c.Addr, Handler: handler}
-
-	go func() {
-		<-ctx.Done()
-		if err := srv.Shutdown(context.Background()); err != nil {
-			l.Error(err, "shutdown metrics server")
+	muxes := make(map[string]*http.ServeMux)
+	mux := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if c.Addr != "0" {
+		mux(c.Addr).Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{
+			ErrorHandling: promhttp.HTTPErrorOnError,
+		}))
+	}
+	if c.PprofAddr != "0" {
+		handler := mux(c.PprofAddr)
+		handler.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
+		handler.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
+		handler.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
+		handler.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
+		handler.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
+		handler.Handle("/debug/pprof/block", pprof.Handler("block"))
+		handler.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
+		handler.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+		handler.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate"))
+	}
+
+	errChan := make(chan error, len(muxes))
+	for addr, handler := range muxes {
+		srv := &http.Server{Addr: addr, Handler: handler}
+		go func() {
+			<-ctx.Done()
+			if err := srv.Shutdown(context.Background()); err != nil {
+				l.Error(err, "shutdown metrics server", "addr", addr)
+			}
+		}()
+		go func() {
+			l.Info("starting metrics server", "addr", addr)
+			errChan <- srv.ListenAndServe()
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errChan:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
 		}
-	}()
+		return err
+	}
+}
+
+var (
+	configValid = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nacosbridge_config_valid",
+		Help: "Whether the last loaded config is valid (1) or invalid (0).",
+	})
+	configValidationErrors = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "nacosbridge_config_validation_errors",
+		Help: "Number of validation errors in the last loaded config.",
+	})
+	ruleServices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nacosbridge_rule_matched_services",
+		Help: "Number of Services each auto-registration rule applies to.",
+	}, []string{"rule"})
+	policyRejectedServices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nacosbridge_policy_rejected_services",
+		Help: "Number of Services with ports rejected by export policies, per registry.",
+	}, []string{"registry"})
+)
 
-	return srv.ListenAndServe()
+func init() {
+	metrics.Registry.MustRegister(configValid, configValidationErrors, ruleServices, policyRejectedServices)
 }
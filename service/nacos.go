@@ -1,7 +1,10 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"reflect"
 	"strconv"
 	"sync"
 
@@ -23,15 +26,16 @@ type Nacos struct {
 
 	namespaceClients map[string]naming_client.INamingClient
 
-	newService map[string]Service
+	// 已注册的实例
 	oldService map[string]Service
+	// 下一次 Build 重新注册所有实例
+	resync bool
 
 	log logr.Logger
 }
 
 func (n *Nacos) init() {
 	n.only.Do(func() {
-		n.newService = make(map[string]Service)
 		n.oldService = make(map[string]Service)
 		n.namespaceClients = make(map[string]naming_client.INamingClient)
 		n.log = log.Log.WithName("nacos")
@@ -46,72 +50,113 @@ func (n *Nacos) Config(config map[string]string) error {
 
 	n.init()
 	// 解析配置参数
-	if address, ok := config["address"]; ok {
-		n.address = address
-	} else {
+	address, ok := config["address"]
+	if !ok {
 		return fmt.Errorf("nacos address is required")
 	}
 
+	port := 8848 // 默认端口
 	if portStr, ok := config["port"]; ok {
-		port, err := strconv.Atoi(portStr)
+		p, err := strconv.Atoi(portStr)
 		if err != nil {
 			return fmt.Errorf("invalid nacos port: %v", err)
 		}
-		n.port = port
-	} else {
-		n.port = 8848 // 默认端口
+		port = p
 	}
 
-	if username, ok := config["username"]; ok {
-		n.username = username
-	}
+	username, password := config["username"], config["password"]
 
-	if password, ok := config["password"]; ok {
-		n.password = password
+	// 连接参数变化时关闭已缓存的客户端, 下次使用时按新参数创建
+	if address != n.address || port != n.port || username != n.username || password != n.password {
+		for namespace, client := range n.namespaceClients {
+			client.CloseClient()
+			delete(n.namespaceClients, namespace)
+		}
+	}
+	// 切换到其它 Nacos 服务端时, 所有实例需要在新服务端重新注册
+	if address != n.address || port != n.port {
+		clear(n.oldService)
 	}
+	n.address = address
+	n.port = port
+	n.username = username
+	n.password = password
 	return nil
 }
 
+// Resync 使下一次 Build 重新注册所有实例, 恢复在 Nacos 中被手动删除的实例
+func (n *Nacos) Resync() {
+	n.resync = true
+}
+
 func (n *Nacos) Build(services []Service) error {
 
+	// 按实例 (命名空间/分组/服务名/IP/端口) 拆分, 同名服务的多个端口以及多个命名空间/分组互不覆盖
+	newService := make(map[string]Service)
 	for _, svc := range services {
 		for _, ip := range svc.IP {
-			svcName := fmt.Sprintf("%s.%s.%s", svc.NacosNs, svc.Name, ip)
-			if _, ok := n.newService[svcName]; !ok {
-				n.newService[svcName] = svc
+			svcName := svc.instanceKey(ip)
+			if _, ok := newService[svcName]; !ok {
+				newService[svcName] = svc.ForAddress(ip)
 			}
 		}
 	}
 
-	addSvc := make([]Service, 0)
-	delSvc := make([]Service, 0)
-	for k, svc := range n.newService {
-		if _, ok := n.oldService[k]; !ok {
-			addSvc = append(addSvc, svc)
-		}
-	}
-
-	for k, svc := range n.oldService {
-		if _, ok := n.newService[k]; !ok {
-			delSvc = append(delSvc, svc)
+	// 每个实例独立对比, 失败的实例保持原状态, 下次重建时重试, 不影响其它命名空间和分组
+	errs := make([]error, 0)
+	resync := n.resync
+	n.resync = false
+	for k, svc := range newService {
+		if old, ok := n.oldService[k]; ok {
+			// 权重、元数据或启用状态变化时更新实例, 全量同步时重新注册未变化的实例
+			if reflect.DeepEqual(old, svc) {
+				if !resync {
+					continue
+				}
+				if err := n.registerService(svc); err != nil {
+					errs = append(errs, &InstanceError{Op: "register", Instance: svc, Err: err})
+				}
+				continue
+			}
+			// 集群名称是实例标识的一部分, 变化时重新注册
+			if old.ClusterName != svc.ClusterName {
+				if err := n.deregisterService(old); err != nil {
+					errs = append(errs, &InstanceError{Op: "deregister", Instance: old, Err: err})
+					continue
+				}
+				delete(n.oldService, k)
+				if err := n.registerService(svc); err != nil {
+					errs = append(errs, &InstanceError{Op: "register", Instance: svc, Err: err})
+					continue
+				}
+				n.oldService[k] = svc
+				continue
+			}
+			if err := n.updateService(svc); err != nil {
+				errs = append(errs, &InstanceError{Op: "update", Instance: svc, Err: err})
+				continue
+			}
+			n.oldService[k] = svc
+			continue
 		}
-	}
-
-	for _, svc := range addSvc {
 		if err := n.registerService(svc); err != nil {
-			return fmt.Errorf("failed to register service %s: %v", svc.Name, err)
+			errs = append(errs, &InstanceError{Op: "register", Instance: svc, Err: err})
+			continue
 		}
+		n.oldService[k] = svc
 	}
 
-	for _, svc := range delSvc {
+	for k, svc := range n.oldService {
+		if _, ok := newService[k]; ok {
+			continue
+		}
 		if err := n.deregisterService(svc); err != nil {
-			return fmt.Errorf("failed to deregister service %s: %v", svc.Name, err)
+			errs = append(errs, &InstanceError{Op: "deregister", Instance: svc, Err: err})
+			continue
 		}
+		delete(n.oldService, k)
 	}
-
-	n.oldService = n.newService
-	n.newService = make(map[string]Service)
-	return nil
+	return errors.Join(errs...)
 }
 
 // registerService 注册单个服务到nacos
@@ -127,21 +172,20 @@ func (n *Nacos) registerService(service Service) error {
 	}
 
 	// 添加调试日志
-	n.log.Info("DEBUG: Attempting to register service", "service", service.Name, "namespace", namespace, "ips", service.IP, "port", service.Port)
+	n.log.Info("DEBUG: Attempting to register service", "service", service.Name, "group", service.Group, "namespace", namespace, "ips", service.IP, "port", service.Port)
 
 	for _, ip := range service.IP {
 		param := vo.RegisterInstanceParam{
 			Ip:          ip,
 			Port:        uint64(service.Port),
 			ServiceName: service.Name,
-			Weight:      10, // 默认权重
-			Enable:      true,
+			GroupName:   service.Group,
+			ClusterName: service.ClusterName,
+			Weight:      service.Weight,
+			Enable:      !service.Disabled,
 			Healthy:     true,
 			Ephemeral:   false,
-			Metadata: map[string]string{
-				"created_by": "nacosbridge.io",
-				"cluster":    "k8s.service",
-			},
+			Metadata:    instanceMetadata(service),
 		}
 
 		// 添加参数调试日志
@@ -161,6 +205,45 @@ func (n *Nacos) registerService(service Service) error {
 	return nil
 }
 
+// updateService 更新已注册实例的权重、元数据和启用状态
+func (n *Nacos) updateService(service Service) error {
+
+	namespace := service.NacosNs
+	if namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	nacosClient, err := n.generateNamespaceClient(namespace)
+	if err != nil {
+		return fmt.Errorf("failed to generate namespace client for %s: %v", namespace, err)
+	}
+
+	for _, ip := range service.IP {
+		param := vo.UpdateInstanceParam{
+			Ip:          ip,
+			Port:        uint64(service.Port),
+			ServiceName: service.Name,
+			GroupName:   service.Group,
+			ClusterName: service.ClusterName,
+			Weight:      service.Weight,
+			Enable:      !service.Disabled,
+			Healthy:     true,
+			Ephemeral:   false,
+			Metadata:    instanceMetadata(service),
+		}
+
+		success, err := nacosClient.UpdateInstance(param)
+		if err != nil {
+			n.log.Error(err, "ERROR: Update instance failed", "ip", ip, "port", service.Port, "serviceName", service.Name)
+			return fmt.Errorf("update instance failed: %v", err)
+		}
+		if !success {
+			return fmt.Errorf("update instance failed: service not updated")
+		}
+		n.log.Info("SUCCESS: Updated instance", "ip", ip, "port", service.Port, "serviceName", service.Name, "weight", service.Weight, "enabled", !service.Disabled)
+	}
+	return nil
+}
+
 func (n *Nacos) deregisterService(service Service) error {
 
 	namespace := service.NacosNs
@@ -173,13 +256,15 @@ func (n *Nacos) deregisterService(service Service) error {
 	}
 
 	// 添加调试日志
-	n.log.Info("DEBUG: Attempting to deregister service", "service", service.Name, "namespace", namespace, "ips", service.IP, "port", service.Port)
+	n.log.Info("DEBUG: Attempting to deregister service", "service", service.Name, "group", service.Group, "namespace", namespace, "ips", service.IP, "port", service.Port)
 
 	for _, ip := range service.IP {
 		param := vo.DeregisterInstanceParam{
 			Ip:          ip,
 			Port:        uint64(service.Port),
 			ServiceName: service.Name,
+			GroupName:   service.Group,
+			Cluster:     service.ClusterName,
 			Ephemeral:   false,
 		}
 
@@ -200,6 +285,17 @@ func (n *Nacos) deregisterService(service Service) error {
 	return nil
 }
 
+// instanceMetadata 返回实例元数据, 附加桥接标记
+func instanceMetadata(service Service) map[string]string {
+	metadata := maps.Clone(service.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["created_by"] = "nacosbridge.io"
+	metadata["cluster"] = "k8s.service"
+	return metadata
+}
+
 func (n *Nacos) generateNamespaceClient(namespace string) (naming_client.INamingClient, error) {
 
 	if client, ok := n.namespaceClients[namespace]; ok {
@@ -235,5 +331,6 @@ func (n *Nacos) generateNamespaceClient(namespace string) (naming_client.INaming
 		return nil, fmt.Errorf("failed to create namespace client for %s: %v", namespace, err)
 	}
 
+	n.namespaceClients[namespace] = client
 	return client, nil
 }
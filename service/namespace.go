@@ -0,0 +1,65 @@
+package service
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 命名空间上的 nacosbridge.io/ 标签和注解作为其中 Service 的默认配置 (注解优先于标签),
+// Service 自身的配置优先; 命名空间设置 nacosbridge.io/enabled=true 时其中所有 Service 开启注册,
+// Service 可以通过 nacosbridge.io/enabled=false 退出
+
+// 不作为默认值下发的命名空间键
+var namespaceOnlyKeys = map[string]bool{
+	REGISTRY_SERVICE_NAME:    true,
+	REGISTRY_ENABLED:         true,
+	REGISTRY_EFFECTIVE_RULE:  true,
+	REGISTRY_POLICY_REJECTED: true,
+}
+
+// NamespaceSettings 返回命名空间提供的默认配置, 键名统一为 v2
+func NamespaceSettings(namespace *corev1.Namespace) map[string]string {
+	settings := make(map[string]string)
+	if namespace == nil {
+		return settings
+	}
+	for _, source := range []map[string]string{namespace.Labels, namespace.Annotations} {
+		for k, v := range source {
+			if !strings.HasPrefix(k, REGISTRY_PREFIX) || namespaceOnlyKeys[k] {
+				continue
+			}
+			if newKey, ok := v2Key(k); ok {
+				k = newKey
+			}
+			settings[k] = v
+		}
+	}
+	return settings
+}
+
+// ApplyNamespaceDefaults 返回写入命名空间默认值的服务副本, 命名空间没有配置时返回原服务
+func ApplyNamespaceDefaults(svc *corev1.Service, namespace *corev1.Namespace) *corev1.Service {
+	settings := NamespaceSettings(namespace)
+	enabled := namespace != nil && (namespace.Labels[REGISTRY_ENABLED] == "true" || namespace.Annotations[REGISTRY_ENABLED] == "true")
+	if len(settings) == 0 && !enabled {
+		return svc
+	}
+
+	cp := svc.DeepCopy()
+	if cp.Labels == nil {
+		cp.Labels = make(map[string]string)
+	}
+	if cp.Annotations == nil {
+		cp.Annotations = make(map[string]string)
+	}
+	if _, ok := cp.Labels[REGISTRY_SERVICE_NAME]; !ok && enabled {
+		cp.Labels[REGISTRY_SERVICE_NAME] = ""
+	}
+	for k, v := range settings {
+		if _, ok := LookupSetting(cp, k); !ok {
+			cp.Annotations[k] = v
+		}
+	}
+	return cp
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// 节点即将下线时添加的污点, 由节点状态判断而不是按普通污点排除
+var drainTaints = map[string]bool{
+	corev1.TaintNodeUnschedulable:    true,
+	corev1.TaintNodeNotReady:         true,
+	corev1.TaintNodeUnreachable:      true,
+	"ToBeDeletedByClusterAutoscaler": true,
+	"karpenter.sh/disrupted":         true,
+}
+
+// NodeSelector 选择用于注册 NodePort 服务的节点
+type NodeSelector struct {
+	selector           labels.Selector
+	addressType        corev1.NodeAddressType
+	includeNotReady    bool
+	includeUnscheduled bool
+	includeTainted     bool
+	readyEndpointNodes map[string]bool
+	drainGracePeriod   time.Duration
+}
+
+// NewNodeSelector 根据全局配置和服务标签/注解生成节点选择器, 服务配置优先
+func NewNodeSelector(svc *corev1.Service, cache *Cache, config *Config) (*NodeSelector, error) {
+	ns := &NodeSelector{
+		selector:           labels.Everything(),
+		addressType:        corev1.NodeInternalIP,
+		includeNotReady:    config.IncludeNotReadyNodes,
+		includeUnscheduled: config.IncludeUnschedulableNodes,
+		includeTainted:     config.IncludeTaintedNodes,
+		drainGracePeriod:   config.nodeDrainGracePeriod,
+	}
+
+	selector := config.NodeSelector
+	if s, ok := LookupSetting(svc, REGISTRY_NODE_SELECTOR); ok {
+		selector = s
+	}
+	if selector != "" {
+		parsed, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node selector %q: %v", selector, err)
+		}
+		ns.selector = parsed
+	}
+
+	addressType := config.NodeAddressType
+	if t := GetSetting(svc, REGISTRY_NODE_ADDRESS_TYPE); t != "" {
+		addressType = t
+	}
+	switch corev1.NodeAddressType(addressType) {
+	case "":
+	case corev1.NodeInternalIP, corev1.NodeExternalIP, corev1.NodeHostName:
+		ns.addressType = corev1.NodeAddressType(addressType)
+	default:
+		return nil, fmt.Errorf("unknown node address type %s", addressType)
+	}
+
+	// Local 策略下只有运行就绪端点的节点才能接收流量
+	if svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyLocal {
+		ns.readyEndpointNodes = make(map[string]bool)
+		for _, es := range cache.EndpointSlicesFor(svc) {
+			for _, ep := range es.Endpoints {
+				if ep.NodeName == nil || (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) {
+					continue
+				}
+				ns.readyEndpointNodes[*ep.NodeName] = true
+			}
+		}
+	}
+	return ns, nil
+}
+
+// Match 判断节点是否可以注册
+func (ns *NodeSelector) Match(node *corev1.Node) bool {
+	return ns.eligible(node) && !ns.draining(node)
+}
+
+// Draining 判断节点是否正在下线且仍在宽限期内, 宽限期内节点上的实例保留注册但被禁用
+func (ns *NodeSelector) Draining(node *corev1.Node, cache *Cache, now time.Time) bool {
+	if ns.drainGracePeriod <= 0 || !ns.eligible(node) || !ns.draining(node) {
+		return false
+	}
+	return now.Before(cache.NodeDrainingSince(node).Add(ns.drainGracePeriod))
+}
+
+// eligible 检查节点选择器、普通污点和就绪端点
+func (ns *NodeSelector) eligible(node *corev1.Node) bool {
+	if !ns.selector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if !ns.includeTainted && slices.ContainsFunc(node.Spec.Taints, func(taint corev1.Taint) bool {
+		return !drainTaints[taint.Key] && (taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute)
+	}) {
+		return false
+	}
+	if ns.readyEndpointNodes != nil && !ns.readyEndpointNodes[node.Name] {
+		return false
+	}
+	return true
+}
+
+// draining 判断节点是否被封锁、未就绪或即将被删除
+func (ns *NodeSelector) draining(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		switch taint.Key {
+		case corev1.TaintNodeUnschedulable:
+			if !ns.includeUnscheduled {
+				return true
+			}
+		case corev1.TaintNodeNotReady, corev1.TaintNodeUnreachable:
+			if !ns.includeNotReady {
+				return true
+			}
+		default:
+			if drainTaints[taint.Key] {
+				return true
+			}
+		}
+	}
+	if !ns.includeUnscheduled && node.Spec.Unschedulable {
+		return true
+	}
+	return !ns.includeNotReady && !IsNodeReady(node)
+}
+
+// Addresses 返回节点上指定类型的地址
+func (ns *NodeSelector) Addresses(node *corev1.Node) []string {
+	addresses := make([]string, 0)
+	for _, address := range node.Status.Addresses {
+		if address.Type == ns.addressType {
+			addresses = appendUnique(addresses, address.Address)
+		}
+	}
+	return addresses
+}
+
+// NodeAddresses 收集所有匹配节点的地址
+func (ns *NodeSelector) NodeAddresses(cache *Cache) []string {
+	addresses := make([]string, 0)
+	for _, node := range cache.nodes {
+		if ns.Match(node) {
+			addresses = appendUnique(addresses, ns.Addresses(node)...)
+		}
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+// DrainingAddresses 收集正在下线且仍在宽限期内的节点地址
+func (ns *NodeSelector) DrainingAddresses(cache *Cache) []string {
+	addresses := make([]string, 0)
+	now := time.Now()
+	for _, node := range cache.nodes {
+		if ns.Draining(node, cache, now) {
+			addresses = appendUnique(addresses, ns.Addresses(node)...)
+		}
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+// IsNodeDraining 判断节点是否被封锁、未就绪或带有下线污点
+func IsNodeDraining(node *corev1.Node) bool {
+	return node.Spec.Unschedulable || !IsNodeReady(node) || slices.ContainsFunc(node.Spec.Taints, func(taint corev1.Taint) bool {
+		return drainTaints[taint.Key]
+	})
+}
+
+// nodeDrainingSince 返回节点开始下线的时间, 优先使用节点状态和污点中记录的时间
+func nodeDrainingSince(node *corev1.Node, firstSeen time.Time) time.Time {
+	since := firstSeen
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue && !condition.LastTransitionTime.IsZero() && condition.LastTransitionTime.Time.Before(since) {
+			since = condition.LastTransitionTime.Time
+		}
+	}
+	for _, taint := range node.Spec.Taints {
+		if drainTaints[taint.Key] && taint.TimeAdded != nil && taint.TimeAdded.Time.Before(since) {
+			since = taint.TimeAdded.Time
+		}
+	}
+	return since
+}
+
+func IsNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
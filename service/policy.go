@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Policy CEL 策略表达式, 结果为 false 时拒绝注册
+//
+// 可用变量:
+//   - service: Kubernetes Service 对象, 显式注册时为空
+//   - namespaceObject: Kubernetes Namespace 对象, 未缓存时为空 (namespace 是 CEL 保留字)
+//   - port: 当前导出的 ServicePort, 显式注册时只有端口号
+//   - registration: 注册信息 (name/group/namespace/port/ips/metadata/weight/enabled)
+type Policy struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+}
+
+type policyProgram struct {
+	policy  Policy
+	program cel.Program
+}
+
+// PolicyError 记录服务被策略拒绝的注册
+type PolicyError struct {
+	Rejections []string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("rejected by policy: %s", strings.Join(e.Rejections, "; "))
+}
+
+func compilePolicies(policies []Policy) ([]policyProgram, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("service", cel.DynType),
+		cel.Variable("namespaceObject", cel.DynType),
+		cel.Variable("port", cel.DynType),
+		cel.Variable("registration", cel.DynType),
+		cel.OptionalTypes(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	programs := make([]policyProgram, 0, len(policies))
+	for i, policy := range policies {
+		if policy.Name == "" {
+			policy.Name = fmt.Sprintf("policies[%d]", i)
+		}
+		ast, issues := env.Compile(policy.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("policy %s: %v", policy.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("policy %s: expression must return bool, got %s", policy.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %v", policy.Name, err)
+		}
+		programs = append(programs, policyProgram{policy: policy, program: program})
+	}
+	return programs, nil
+}
+
+// evaluatePolicies 对每个注册信息执行策略, 返回通过的注册信息和被拒绝的原因
+// 表达式执行出错时同样拒绝注册
+func evaluatePolicies(programs []policyProgram, svc *corev1.Service, namespace *corev1.Namespace, serviceInfos []Service) ([]Service, error) {
+	if len(programs) == 0 || len(serviceInfos) == 0 {
+		return serviceInfos, nil
+	}
+
+	svcObj := map[string]interface{}{}
+	if svc != nil {
+		var err error
+		if svcObj, err = runtime.DefaultUnstructuredConverter.ToUnstructured(svc); err != nil {
+			return nil, err
+		}
+	}
+	nsObj := map[string]interface{}{}
+	var err error
+	if namespace != nil {
+		if nsObj, err = runtime.DefaultUnstructuredConverter.ToUnstructured(namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	allowed := make([]Service, 0, len(serviceInfos))
+	rejections := make([]string, 0)
+	for _, info := range serviceInfos {
+		portObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&info.servicePort)
+		if err != nil {
+			return nil, err
+		}
+		vars := map[string]interface{}{
+			"service":         svcObj,
+			"namespaceObject": nsObj,
+			"port":            portObj,
+			"registration": map[string]interface{}{
+				"name":      info.Name,
+				"group":     info.Group,
+				"namespace": info.NacosNs,
+				"port":      int64(info.Port),
+				"ips":       info.IP,
+				"metadata":  info.Metadata,
+				"weight":    info.Weight,
+				"enabled":   !info.Disabled,
+			},
+		}
+
+		rejected := ""
+		for _, p := range programs {
+			out, _, err := p.program.Eval(vars)
+			if err != nil {
+				rejected = fmt.Sprintf("%s: %v", p.policy.Name, err)
+				break
+			}
+			if ok, isBool := out.Value().(bool); !isBool || !ok {
+				message := p.policy.Message
+				if message == "" {
+					message = p.policy.Expression
+				}
+				rejected = fmt.Sprintf("%s: %s", p.policy.Name, message)
+				break
+			}
+		}
+		if rejected != "" {
+			rejections = append(rejections, fmt.Sprintf("%s:%d %s", info.Name, info.Port, rejected))
+			continue
+		}
+		allowed = append(allowed, info)
+	}
+
+	if len(rejections) > 0 {
+		return allowed, &PolicyError{Rejections: rejections}
+	}
+	return allowed, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// 开放全部端口, 标签值不能包含 *, 因此同时支持 all
+	OPENPORT_ALL      = "*"
+	OPENPORT_ALL_NAME = "all"
+)
+
+// PortSelector 选择需要注册的端口
+type PortSelector struct {
+	all     bool
+	entries map[string]bool
+}
+
+// OpenPorts 解析 nacosbridge.io/openport, 注解优先于标签
+// 每一项可以是端口名称、端口号或 appProtocol; 注解使用逗号分隔, 标签值不能包含逗号, 因此也可以使用下划线分隔
+func OpenPorts(svc *corev1.Service) PortSelector {
+	var entries []string
+	if value, ok := svc.Annotations[REGISTRY_OPENPORT]; ok {
+		entries = strings.Split(value, ",")
+	} else if value, ok := svc.Labels[REGISTRY_OPENPORT]; ok {
+		entries = strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == '_'
+		})
+	}
+
+	ps := PortSelector{entries: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch entry {
+		case "":
+		case OPENPORT_ALL, OPENPORT_ALL_NAME:
+			ps.all = true
+		default:
+			ps.entries[entry] = true
+		}
+	}
+	return ps
+}
+
+func (ps PortSelector) Match(port corev1.ServicePort) bool {
+	if ps.all {
+		return true
+	}
+	if port.Name != "" && ps.entries[port.Name] {
+		return true
+	}
+	if ps.entries[strconv.Itoa(int(port.Port))] {
+		return true
+	}
+	return port.AppProtocol != nil && ps.entries[*port.AppProtocol]
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"nacosbridge/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RegistrationRegistry 返回显式注册的目标注册中心
+func RegistrationRegistry(reg *v1alpha1.NacosRegistration) string {
+	if reg.Spec.Registry == "" {
+		return (&Nacos{}).Name()
+	}
+	return reg.Spec.Registry
+}
+
+// NamespaceNotAllowedError 显式注册的 spec.namespace 与所在命名空间的映射结果不一致
+type NamespaceNotAllowedError struct {
+	Namespace string
+	NacosNs   string
+	Resolved  string
+}
+
+func (e *NamespaceNotAllowedError) Error() string {
+	if e.Resolved == "" {
+		return fmt.Sprintf("spec.namespace %s is not allowed, namespace_mapping does not map namespace %s", e.NacosNs, e.Namespace)
+	}
+	return fmt.Sprintf("spec.namespace %s is not allowed, namespace_mapping maps namespace %s to %s", e.NacosNs, e.Namespace, e.Resolved)
+}
+
+// GenerateRegistrationInfos 将 NacosRegistration 转换为注册信息, 同一端口的实例合并为一个 Service
+// Nacos 命名空间与 Service 一样由 namespace_mapping (包括团队配置) 决定, spec.namespace 只能与映射结果一致
+// 元数据: 实例元数据 > spec 元数据 > 自动元数据 > 静态元数据
+func GenerateRegistrationInfos(reg *v1alpha1.NacosRegistration, config *Config) ([]Service, error) {
+	nacosNs, err := config.NamespaceMapping.Resolve(reg.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("namespace mapping: %v", err)
+	}
+	if reg.Spec.Namespace != "" && reg.Spec.Namespace != nacosNs {
+		return nil, &NamespaceNotAllowedError{Namespace: reg.Namespace, NacosNs: reg.Spec.Namespace, Resolved: nacosNs}
+	}
+	if nacosNs == "" {
+		return nil, fmt.Errorf("nacos namespace is required, set spec.namespace or namespace_mapping")
+	}
+
+	metadata := maps.Clone(config.Metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	auto := map[string]string{
+		METADATA_K8S_CLUSTER:    config.ClusterName,
+		METADATA_K8S_NAMESPACE:  reg.Namespace,
+		METADATA_K8S_UID:        string(reg.UID),
+		METADATA_BRIDGE_VERSION: Version,
+	}
+	for k, v := range auto {
+		if v != "" && autoMetadataEnabled(config, k) {
+			metadata[k] = v
+		}
+	}
+	maps.Copy(metadata, reg.Spec.Metadata)
+
+	infos := make([]Service, 0)
+	index := make(map[int32]int)
+	for _, instance := range reg.Spec.Instances {
+		i, ok := index[instance.Port]
+		if !ok {
+			i = len(infos)
+			index[instance.Port] = i
+			infos = append(infos, Service{
+				Name:        reg.Spec.ServiceName,
+				Group:       reg.Spec.Group,
+				Port:        instance.Port,
+				NacosNs:     nacosNs,
+				Metadata:    maps.Clone(metadata),
+				Weight:      DEFAULT_WEIGHT,
+				ClusterName: reg.Spec.Cluster,
+				servicePort: corev1.ServicePort{Port: instance.Port},
+			})
+		}
+		// 重复的实例只使用第一个
+		if slices.Contains(infos[i].IP, instance.IP) {
+			continue
+		}
+		infos[i].IP = append(infos[i].IP, instance.IP)
+		infos[i].setOverride(instance.IP, func(o *InstanceOverride) {
+			if instance.Weight != nil {
+				weight := float64(*instance.Weight)
+				o.Weight = &weight
+			}
+			o.Disabled = instance.Enabled != nil && !*instance.Enabled
+			o.Metadata = maps.Clone(instance.Metadata)
+		})
+	}
+	return infos, nil
+}
+
+// evaluateRegistrationPolicies 按端口对显式注册执行导出策略, 返回通过的注册信息和被拒绝端口的原因
+// namespaceObject 为注册所在的命名空间, service 为空
+func evaluateRegistrationPolicies(programs []policyProgram, namespace *corev1.Namespace, infos []Service) ([]Service, map[int32]error, error) {
+	allowed := make([]Service, 0, len(infos))
+	rejected := make(map[int32]error)
+	for _, info := range infos {
+		passed, err := evaluatePolicies(programs, nil, namespace, []Service{info})
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			rejected[info.Port] = policyErr
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		allowed = append(allowed, passed...)
+	}
+	return allowed, rejected, nil
+}
+
+// registrationResult 显式注册的同步结果
+type registrationResult struct {
+	// 整体失败的原因, 为空时按实例的同步结果设置状态
+	reason string
+	err    error
+	infos  []Service
+	// 被策略拒绝的端口
+	rejected map[int32]error
+}
+
+// registrationStatusUpdate 回写 NacosRegistration 的实例状态, failed 为同步失败的实例
+func registrationStatusUpdate(reg *v1alpha1.NacosRegistration, result registrationResult, failed map[string]error) StatusUpdate {
+	instances := make([]v1alpha1.InstanceStatus, 0, len(reg.Spec.Instances))
+	instanceConditions := make([]metav1.Condition, 0, len(reg.Spec.Instances))
+	failures := 0
+	for _, instance := range reg.Spec.Instances {
+		if slices.ContainsFunc(instances, func(s v1alpha1.InstanceStatus) bool {
+			return s.IP == instance.IP && s.Port == instance.Port
+		}) {
+			continue
+		}
+		condition := metav1.Condition{
+			Type:    v1alpha1.ConditionRegistered,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.ReasonRegistered,
+			Message: "instance is registered",
+		}
+		if instance.Enabled != nil && !*instance.Enabled {
+			condition.Message = "instance is registered and disabled"
+		}
+		if result.reason != "" {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = result.reason
+			condition.Message = result.err.Error()
+		} else if err, ok := result.rejected[instance.Port]; ok {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = v1alpha1.ReasonPolicyRejected
+			condition.Message = err.Error()
+		} else {
+			for _, info := range result.infos {
+				if info.Port != instance.Port {
+					continue
+				}
+				if err, ok := failed[info.instanceKey(instance.IP)]; ok {
+					condition.Status = metav1.ConditionFalse
+					condition.Reason = v1alpha1.ReasonRegisterFailed
+					condition.Message = err.Error()
+				}
+			}
+		}
+		if condition.Status == metav1.ConditionFalse {
+			failures++
+		}
+		instances = append(instances, v1alpha1.InstanceStatus{IP: instance.IP, Port: instance.Port})
+		instanceConditions = append(instanceConditions, condition)
+	}
+
+	ready := metav1.Condition{
+		Type:    v1alpha1.ConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.ReasonSynced,
+		Message: "all instances are registered",
+	}
+	if result.reason != "" {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = result.reason
+		ready.Message = result.err.Error()
+	} else if failures > 0 {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = v1alpha1.ReasonSyncFailed
+		ready.Message = fmt.Sprintf("%d of %d instances failed to register", failures, len(instances))
+	}
+
+	generation := reg.Generation
+	mutator := func(obj client.Object) client.Object {
+		cp := obj.(*v1alpha1.NacosRegistration).DeepCopy()
+		// 保留已有条件的 lastTransitionTime
+		statuses := slices.Clone(instances)
+		for i := range statuses {
+			for _, old := range cp.Status.Instances {
+				if old.IP == statuses[i].IP && old.Port == statuses[i].Port {
+					statuses[i].Conditions = old.Conditions
+				}
+			}
+			condition := instanceConditions[i]
+			condition.ObservedGeneration = generation
+			meta.SetStatusCondition(&statuses[i].Conditions, condition)
+		}
+		cp.Status.Instances = statuses
+		ready.ObservedGeneration = generation
+		meta.SetStatusCondition(&cp.Status.Conditions, ready)
+		cp.Status.ObservedGeneration = generation
+		return cp
+	}
+	return NewStatusUpdate(reg.Name, reg.Namespace, &v1alpha1.NacosRegistration{}, StatusMutatorFunc(mutator))
+}
+
+// failedInstances 按实例标识索引 Build 失败的实例
+func failedInstances(err error) map[string]error {
+	failed := make(map[string]error)
+	for _, e := range InstanceErrors(err) {
+		for _, ip := range e.Instance.IP {
+			failed[e.Instance.instanceKey(ip)] = e
+		}
+	}
+	return failed
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"nacosbridge/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newRegistration(nacosNs string, ports ...int32) *v1alpha1.NacosRegistration {
+	reg := &v1alpha1.NacosRegistration{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm", Namespace: "team-a"},
+		Spec:       v1alpha1.NacosRegistrationSpec{ServiceName: "vm", Namespace: nacosNs},
+	}
+	for _, port := range ports {
+		reg.Spec.Instances = append(reg.Spec.Instances, v1alpha1.RegistrationInstance{IP: "10.0.0.1", Port: port})
+	}
+	return reg
+}
+
+func TestGenerateRegistrationInfosNamespace(t *testing.T) {
+	config := &Config{NamespaceMapping: NamespaceMapping{
+		Namespaces: []NamespaceMappingEntry{{Namespace: "team-a", NacosNamespace: "team-a-id"}},
+	}}
+
+	tests := []struct {
+		name    string
+		nacosNs string
+		want    string
+		allowed bool
+	}{
+		{name: "mapped", want: "team-a-id", allowed: true},
+		{name: "same as mapping", nacosNs: "team-a-id", want: "team-a-id", allowed: true},
+		{name: "other namespace", nacosNs: "team-b-id"},
+	}
+	for _, tt := range tests {
+		infos, err := GenerateRegistrationInfos(newRegistration(tt.nacosNs, 8080), config)
+		var nsErr *NamespaceNotAllowedError
+		if !tt.allowed {
+			if !errors.As(err, &nsErr) {
+				t.Errorf("%s: expected NamespaceNotAllowedError, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if infos[0].NacosNs != tt.want {
+			t.Errorf("%s: nacos namespace = %q, want %q", tt.name, infos[0].NacosNs, tt.want)
+		}
+	}
+
+	// 没有映射时不能通过 spec.namespace 指定
+	_, err := GenerateRegistrationInfos(newRegistration("team-a-id", 8080), &Config{})
+	var nsErr *NamespaceNotAllowedError
+	if !errors.As(err, &nsErr) {
+		t.Errorf("unmapped namespace: expected NamespaceNotAllowedError, got %v", err)
+	}
+}
+
+func TestEvaluateRegistrationPolicies(t *testing.T) {
+	config := &Config{
+		NamespaceMapping: NamespaceMapping{Identity: true},
+		Policies: []Policy{
+			{Name: "prod-only", Expression: `namespaceObject.?metadata.?labels[?"tier"].orValue("") == "prod" || port.port != 22`},
+		},
+	}
+	programs, err := compilePolicies(config.Policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := newRegistration("", 22, 8080)
+	infos, err := GenerateRegistrationInfos(reg, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"tier": "dev"}}}
+	allowed, rejected, err := evaluateRegistrationPolicies(programs, dev, infos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 1 || allowed[0].Port != 8080 {
+		t.Fatalf("expected only port 8080 to be allowed, got %+v", allowed)
+	}
+	if _, ok := rejected[22]; !ok || len(rejected) != 1 {
+		t.Fatalf("expected port 22 to be rejected, got %v", rejected)
+	}
+
+	// 被拒绝端口的实例条件为 PolicyRejected
+	update := registrationStatusUpdate(reg, registrationResult{infos: allowed, rejected: rejected}, nil)
+	got := update.Mutator.Mutate(reg.DeepCopy()).(*v1alpha1.NacosRegistration)
+	for _, instance := range got.Status.Instances {
+		condition := meta.FindStatusCondition(instance.Conditions, v1alpha1.ConditionRegistered)
+		want := v1alpha1.ReasonRegistered
+		if instance.Port == 22 {
+			want = v1alpha1.ReasonPolicyRejected
+		}
+		if condition == nil || condition.Reason != want {
+			t.Errorf("port %d: expected reason %s, got %+v", instance.Port, want, condition)
+		}
+	}
+
+	prod := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"tier": "prod"}}}
+	if _, rejected, err := evaluateRegistrationPolicies(programs, prod, infos); err != nil || len(rejected) != 0 {
+		t.Fatalf("expected all ports to be allowed in prod, got %v, %v", rejected, err)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Rule 自动注册规则, 匹配的服务不需要 nacosbridge.io/service 标签即可导出
+type Rule struct {
+	Name string `json:"name"`
+
+	// 命名空间名称, 支持通配符; 为空时匹配全部命名空间
+	Namespaces []string `json:"namespaces"`
+	// 命名空间标签选择器
+	NamespaceSelector string `json:"namespace_selector"`
+	// 服务标签选择器
+	Selector string `json:"selector"`
+
+	// 以下字段作为服务配置的默认值, 服务自身的标签/注解优先
+	ServiceType string `json:"service_type"`
+	OpenPort    string `json:"openport"`
+	ServiceName string `json:"service_name"`
+	Namespace   string `json:"namespace"`
+	Group       string `json:"group"`
+}
+
+// Match 判断服务是否匹配规则
+func (r *Rule) Match(svc *corev1.Service, cache *Cache) (bool, error) {
+	if len(r.Namespaces) > 0 {
+		matched := false
+		for _, pattern := range r.Namespaces {
+			ok, err := path.Match(pattern, svc.Namespace)
+			if err != nil {
+				return false, fmt.Errorf("invalid namespace pattern %q: %v", pattern, err)
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if r.NamespaceSelector != "" {
+		selector, err := labels.Parse(r.NamespaceSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid namespace selector %q: %v", r.NamespaceSelector, err)
+		}
+		namespace := cache.Namespace(svc.Namespace)
+		if namespace == nil || !selector.Matches(labels.Set(namespace.Labels)) {
+			return false, nil
+		}
+	}
+
+	if r.Selector != "" {
+		selector, err := labels.Parse(r.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid selector %q: %v", r.Selector, err)
+		}
+		if !selector.Matches(labels.Set(svc.Labels)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Apply 返回写入规则默认值的服务副本, 已存在的标签/注解保持不变
+func (r *Rule) Apply(svc *corev1.Service) *corev1.Service {
+	cp := svc.DeepCopy()
+	if cp.Labels == nil {
+		cp.Labels = make(map[string]string)
+	}
+	if cp.Annotations == nil {
+		cp.Annotations = make(map[string]string)
+	}
+	if _, ok := cp.Labels[REGISTRY_SERVICE_NAME]; !ok {
+		cp.Labels[REGISTRY_SERVICE_NAME] = ""
+	}
+
+	defaults := map[string]string{
+		REGISTRY_SERVICE_TYPE:          r.ServiceType,
+		REGISTRY_OPENPORT:              r.OpenPort,
+		REGISTRY_SERVICE_NAME_TEMPLATE: r.ServiceName,
+		REGISTRY_SERVICE_NAMESPACE:     r.Namespace,
+		REGISTRY_SERVICE_GROUP:         r.Group,
+	}
+	for k, v := range defaults {
+		if v == "" {
+			continue
+		}
+		if _, ok := LookupSetting(cp, k); !ok {
+			cp.Annotations[k] = v
+		}
+	}
+	return cp
+}
+
+// ApplyRules 按顺序匹配规则, 返回应用第一个匹配规则后的服务; 没有匹配时返回原服务
+func ApplyRules(svc *corev1.Service, cache *Cache, config *Config) (*corev1.Service, *Rule, error) {
+	for i := range config.Rules {
+		rule := config.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		matched, err := rule.Match(svc, cache)
+		if err != nil {
+			return svc, nil, fmt.Errorf("rule %s: %v", rule.Name, err)
+		}
+		if matched {
+			return rule.Apply(svc), &rule, nil
+		}
+	}
+	return svc, nil, nil
+}
@@ -1,29 +1,89 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"nacosbridge/api/v1alpha1"
+
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 const (
 	DELAY = 10 * time.Second
+
+	// 检查配置文件变化的间隔
+	CONFIG_FILE_INTERVAL = 10 * time.Second
 )
 
+// ServerOptions 同步流程的参数
+type ServerOptions struct {
+	// 最后一次变更后等待多久重建, 用于合并短时间内的多次变更, 默认 DELAY
+	Delay time.Duration
+	// 持续变更时距第一个未处理的变更最多等待多久重建, 0 表示不限制
+	MaxWait time.Duration
+	// 没有变更时定期重建并同步到注册中心的间隔, 0 表示不定期重建
+	ResyncPeriod time.Duration
+}
+
+var errConfigNotFound = errors.New("config not found")
+
 type Registry interface {
 	Name() string
 	Config(config map[string]string) error
 	Build(services []Service) error
 }
 
+// Resyncer 支持全量同步的注册中心, 定期同步时调用
+type Resyncer interface {
+	Resync()
+}
+
+// InstanceError 单个实例同步失败, Build 返回的错误由多个 InstanceError 合并而成
+type InstanceError struct {
+	Op       string
+	Instance Service
+	Err      error
+}
+
+func (e *InstanceError) Error() string {
+	return fmt.Sprintf("failed to %s service %s in %s/%s: %v", e.Op, e.Instance.Name, e.Instance.NacosNs, e.Instance.Group, e.Err)
+}
+
+func (e *InstanceError) Unwrap() error {
+	return e.Err
+}
+
+// InstanceErrors 拆分 Build 返回的错误
+func InstanceErrors(err error) []*InstanceError {
+	switch e := err.(type) {
+	case *InstanceError:
+		return []*InstanceError{e}
+	case interface{ Unwrap() []error }:
+		errs := make([]*InstanceError, 0)
+		for _, inner := range e.Unwrap() {
+			errs = append(errs, InstanceErrors(inner)...)
+		}
+		return errs
+	}
+	return nil
+}
+
 type opAdd struct {
 	obj interface{}
 }
@@ -38,9 +98,37 @@ type Server struct {
 	svcRegistry   []Registry
 	logger        logr.Logger
 	statusUpdater StatusUpdater
+
+	// 已记录废弃警告的服务及其 ResourceVersion
+	deprecated map[types.NamespacedName]string
+	// 服务当前生效的自动注册规则
+	rules map[types.NamespacedName]string
+	// 服务当前被策略拒绝的原因
+	rejections map[types.NamespacedName]string
+	// 距离下一个下线节点宽限期结束的时间, 到期后需要重建
+	requeue time.Duration
+	// 已记录 ConfigMap 配置的废弃警告
+	configMapWarned bool
+	// 已记录兼容警告的命名空间外的全局配置 ConfigMap
+	legacyWarned string
+	// 上一次有效的配置, 新配置无效时继续使用
+	lastConfig *Config
+	// 团队配置上一次有效的版本
+	lastTeamConfigs map[types.NamespacedName]*configFragment
+	// 已记录事件的无效配置及其 ResourceVersion
+	invalidConfigs map[types.UID]string
+	recorder       record.EventRecorder
+	// NacosBridge 所在命名空间, 其中的配置 ConfigMap 为全局配置
+	namespace string
+	// 配置文件路径, 设置后替代 NacosBridgeConfig 和全局 ConfigMap
+	configFile string
+	opts       ServerOptions
 }
 
-func NewService(statusUpdater StatusUpdater) *Server {
+func NewService(statusUpdater StatusUpdater, opts ServerOptions) *Server {
+	if opts.Delay <= 0 {
+		opts.Delay = DELAY
+	}
 	return &Server{
 		cache:      &Cache{},
 		updateChan: make(chan interface{}),
@@ -49,6 +137,14 @@ func NewService(statusUpdater StatusUpdater) *Server {
 		},
 		logger:        log.Log.WithName("service"),
 		statusUpdater: statusUpdater,
+		deprecated:    make(map[types.NamespacedName]string),
+		rules:         make(map[types.NamespacedName]string),
+		rejections:    make(map[types.NamespacedName]string),
+
+		lastTeamConfigs: make(map[types.NamespacedName]*configFragment),
+		invalidConfigs:  make(map[types.UID]string),
+		namespace:       os.Getenv("POD_NAMESPACE"),
+		opts:            opts,
 	}
 }
 
@@ -64,12 +160,70 @@ func (s *Server) OnDelete(obj interface{}) {
 	s.updateChan <- opDelete{obj: obj}
 }
 
+func (s *Server) InjectRecorder(recorder record.EventRecorder) {
+	s.recorder = recorder
+}
+
+// SetConfigFile 使用磁盘上的配置文件, 每次重建时重新读取
+func (s *Server) SetConfigFile(path string) {
+	s.configFile = path
+}
+
 func (s *Server) Start(ctx context.Context) error {
 
 	var (
 		pending <-chan time.Time
 		t       *time.Timer
+		resync  <-chan time.Time
+		// 第一个未处理的变更的最晚重建时间
+		deadline time.Time
+		// 节点下线宽限期到期需要重建的时间
+		requeueAt time.Time
+		// 定期检查配置文件, 内容变化时重建
+		configFile        <-chan time.Time
+		configFileContent []byte
 	)
+	if s.opts.ResyncPeriod > 0 {
+		ticker := time.NewTicker(s.opts.ResyncPeriod)
+		defer ticker.Stop()
+		resync = ticker.C
+	}
+	if s.configFile != "" {
+		configFileContent, _ = os.ReadFile(s.configFile)
+		ticker := time.NewTicker(CONFIG_FILE_INTERVAL)
+		defer ticker.Stop()
+		configFile = ticker.C
+	}
+
+	schedule := func() {
+		if s.opts.MaxWait > 0 && deadline.IsZero() {
+			deadline = time.Now().Add(s.opts.MaxWait)
+		}
+		// 宽限期定时更早到期时保留, 不被防抖推迟
+		delay := scheduleDelay(s.opts.Delay, deadline, requeueAt)
+		if t != nil {
+			t.Stop()
+		}
+		t = time.NewTimer(delay)
+		pending = t.C
+	}
+
+	rebuild := func() {
+		if t != nil {
+			t.Stop()
+		}
+		pending = nil
+		deadline = time.Time{}
+		requeueAt = time.Time{}
+		if err := s.rebuild(); err != nil {
+			s.logger.Error(err, "failed to rebuild")
+		}
+		if s.requeue > 0 {
+			requeueAt = time.Now().Add(s.requeue)
+			t = time.NewTimer(s.requeue)
+			pending = t.C
+		}
+	}
 
 	for {
 		select {
@@ -77,20 +231,39 @@ func (s *Server) Start(ctx context.Context) error {
 			return nil
 		case op := <-s.updateChan:
 			if s.onUpdate(op) {
-				if t != nil {
-					t.Stop()
-				}
-				t = time.NewTimer(DELAY)
-				pending = t.C
+				schedule()
+			}
+		case <-configFile:
+			// 读取失败时内容为空, 文件恢复后触发重建
+			content, _ := os.ReadFile(s.configFile)
+			if !bytes.Equal(content, configFileContent) {
+				configFileContent = content
+				s.logger.Info("config file changed", "path", s.configFile)
+				schedule()
 			}
 		case <-pending:
-			if err := s.rebuild(); err != nil {
-				s.logger.Error(err, "failed to rebuild")
+			rebuild()
+		case <-resync:
+			for _, sr := range s.svcRegistry {
+				if r, ok := sr.(Resyncer); ok {
+					r.Resync()
+				}
 			}
+			rebuild()
 		}
 	}
 }
 
+// scheduleDelay 返回防抖延迟与各个截止时间中最早到期的等待时间, 零值的截止时间不生效
+func scheduleDelay(delay time.Duration, deadlines ...time.Time) time.Duration {
+	for _, d := range deadlines {
+		if !d.IsZero() {
+			delay = min(delay, time.Until(d))
+		}
+	}
+	return delay
+}
+
 func (s *Server) onUpdate(obj interface{}) bool {
 	switch obj := obj.(type) {
 	case opAdd:
@@ -101,77 +274,341 @@ func (s *Server) onUpdate(obj interface{}) bool {
 	return false
 }
 
-func (s *Server) rebuild() error {
+// configSource 生效配置的来源
+type configSource struct {
+	bridgeConfig *BridgeConfig
+	// 每个配置对象的校验结果
+	reports []configReport
+}
 
-	var config string
+// configReport 单个配置对象的校验结果
+type configReport struct {
+	obj client.Object
+	err error
+	// 配置无效时继续使用上一次有效的配置
+	fallback bool
+}
+
+// loadConfig 加载生效的配置, 优先使用 --config 指定的配置文件, 其次是 NacosBridgeConfig, 都没有时合并带有 nacosbridge.io/config 标签的全局 ConfigMap
+// 存在多个 NacosBridgeConfig 时按创建时间和名称排序, 使用第一个; 团队 ConfigMap 在两种情况下都会合并
+func (s *Server) loadConfig() (*Config, configSource, error) {
+	source := configSource{}
+	overrides := envOverrides()
+
+	configmaps := make([]*corev1.ConfigMap, 0)
 	for _, c := range s.cache.configmaps {
-		if c.Labels != nil && c.Labels[REGISTRY_CONFIG] == "true" {
-			content, ok := c.Data["config.json"]
-			if !ok {
-				continue
+		if c.Labels == nil || c.Labels[REGISTRY_CONFIG] != "true" {
+			continue
+		}
+		if HasConfig(c) {
+			configmaps = append(configmaps, c)
+		}
+	}
+	legacy := s.legacyConfigMap(configmaps)
+
+	globals := make([]*corev1.ConfigMap, 0)
+	teams := make([]*configFragment, 0)
+	seen := make(map[types.NamespacedName]bool)
+	for _, c := range configmaps {
+		// 未设置 NacosBridge 所在命名空间时, 所有 ConfigMap 都是全局配置
+		if s.namespace == "" || c.Namespace == s.namespace || c == legacy {
+			globals = append(globals, c)
+			continue
+		}
+		nn := NamespacedName(c)
+		seen[nn] = true
+		fragment, err := newConfigFragment(c, true)
+		if err != nil {
+			last, ok := s.lastTeamConfigs[nn]
+			source.reports = append(source.reports, configReport{obj: c, err: err, fallback: ok})
+			if ok {
+				teams = append(teams, last)
 			}
-			config = content
-			break
+			continue
+		}
+		s.lastTeamConfigs[nn] = fragment
+		source.reports = append(source.reports, configReport{obj: c})
+		teams = append(teams, fragment)
+	}
+	for nn := range s.lastTeamConfigs {
+		if !seen[nn] {
+			delete(s.lastTeamConfigs, nn)
 		}
 	}
+	sortConfigFragments(teams)
 
-	if config == "" {
-		return fmt.Errorf("config not found")
+	if s.configFile != "" {
+		registryConfig := &Config{envOverrides: overrides}
+		content, err := ReadConfigFile(s.configFile)
+		if err == nil {
+			err = registryConfig.Load(content)
+		}
+		if err == nil {
+			err = registryConfig.applyTeamConfigs(teams)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", s.configFile, err)
+		}
+		source.reports = append(source.reports, configReport{err: err})
+		if err != nil {
+			return nil, source, err
+		}
+		return registryConfig, source, nil
 	}
 
-	registryConfig := &Config{}
-	if err := registryConfig.Load(config); err != nil {
-		return err
+	bridgeConfigs := slices.Collect(maps.Values(s.cache.bridgeConfigs))
+	slices.SortFunc(bridgeConfigs, func(a, b *BridgeConfig) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	if len(bridgeConfigs) > 0 {
+		active := bridgeConfigs[0]
+		for _, ignored := range bridgeConfigs[1:] {
+			s.statusUpdater.Send(bridgeConfigStatusUpdate(ignored, metav1.Condition{
+				Type:    v1alpha1.ConditionReady,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.ReasonIgnored,
+				Message: fmt.Sprintf("NacosBridgeConfig %s is in use", active.Name),
+			}, nil, s.opts.ResyncPeriod))
+		}
+		source.bridgeConfig = active
+		registryConfig := &Config{envOverrides: overrides}
+		err := registryConfig.LoadBridgeConfig(active)
+		if err == nil {
+			err = registryConfig.applyTeamConfigs(teams)
+		}
+		source.reports = append(source.reports, configReport{obj: active.NacosBridgeConfig, err: err, fallback: err != nil && s.lastConfig != nil})
+		if err != nil {
+			return nil, source, err
+		}
+		return registryConfig, source, nil
 	}
 
-	sus := make([]StatusUpdate, 0)
-	for nn, svc := range s.cache.services {
+	if len(globals) == 0 {
+		return nil, source, errConfigNotFound
+	}
+	if !s.configMapWarned {
+		s.configMapWarned = true
+		s.logger.Info("configuring from a ConfigMap is deprecated, run `nacosbridge migrate-config` to create a NacosBridgeConfig", "configmap", NamespacedName(globals[0]).String())
+	}
 
-		if svc.Labels == nil {
+	// 任一全局配置无效时整体无效
+	fragments := make([]*configFragment, 0, len(globals))
+	errs := make([]string, 0)
+	for _, c := range globals {
+		fragment, err := newConfigFragment(c, false)
+		source.reports = append(source.reports, configReport{obj: c, err: err, fallback: err != nil && s.lastConfig != nil})
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			for _, e := range validationErr.Errors {
+				errs = append(errs, fmt.Sprintf("%s: %s", NamespacedName(c), e))
+			}
 			continue
 		}
-		if _, ok := svc.Labels[REGISTRY_SERVICE_NAME]; !ok {
+		fragments = append(fragments, fragment)
+	}
+	if len(errs) > 0 {
+		return nil, source, &ValidationError{Errors: errs}
+	}
+	sortConfigFragments(fragments)
+
+	registryConfig := &Config{envOverrides: overrides}
+	content, err := mergeGlobalConfigs(fragments)
+	if err == nil {
+		err = registryConfig.Load(content)
+	}
+	if err == nil {
+		err = registryConfig.applyTeamConfigs(teams)
+	}
+	if err != nil {
+		// 合并后的错误记录在优先级最高的全局配置上
+		for i := range source.reports {
+			if source.reports[i].obj == fragments[0].configmap {
+				source.reports[i].err = err
+				source.reports[i].fallback = s.lastConfig != nil
+			}
+		}
+		return nil, source, err
+	}
+	return registryConfig, source, nil
+}
+
+// legacyConfigMap 兼容升级前的部署: 只有一个配置 ConfigMap 且不在 NacosBridge 所在命名空间,
+// 也没有 NacosBridgeConfig 和配置文件时, 仍将其作为全局配置
+func (s *Server) legacyConfigMap(configmaps []*corev1.ConfigMap) *corev1.ConfigMap {
+	if s.namespace == "" || s.configFile != "" || len(s.cache.bridgeConfigs) > 0 || len(configmaps) != 1 {
+		return nil
+	}
+	c := configmaps[0]
+	if c.Namespace == s.namespace {
+		return nil
+	}
+	if nn := NamespacedName(c).String(); s.legacyWarned != nn {
+		s.legacyWarned = nn
+		s.logger.Info("the only config ConfigMap is outside the NacosBridge namespace and is used as the global config, move it to the NacosBridge namespace before adding more config ConfigMaps, otherwise it becomes a team config that can only set rules and namespace mappings",
+			"configmap", nn, "namespace", s.namespace)
+	}
+	return c
+}
+
+// reportConfigs 记录配置校验结果, 配置无效时在配置对象上记录事件, 同一版本只记录一次
+func (s *Server) reportConfigs(reports []configReport) {
+	// 只统计校验错误, 读取凭据失败等其它错误只使配置无效
+	valid, errCount := true, 0
+	for _, r := range reports {
+		if r.err == nil {
 			continue
 		}
+		valid = false
+		var validationErr *ValidationError
+		if errors.As(r.err, &validationErr) {
+			errCount += len(validationErr.Errors)
+		}
+	}
+	configValidationErrors.Set(float64(errCount))
+	if valid {
+		configValid.Set(1)
+	} else {
+		configValid.Set(0)
+	}
 
-		mutator := func(obj client.Object) client.Object {
-			svc := obj.(*corev1.Service)
-			if svc.Labels == nil || svc.Labels[REGISTRY_SERVICE_EXTERNAL] != "true" {
-				return svc
-			}
-			cp := svc.DeepCopy()
-			for k, specPort := range cp.Spec.Ports {
-				nodePort, ok := svc.Labels[REGISTRY_PORTNAME+specPort.Name]
-				if !ok {
-					continue
-				}
-				port, err := strconv.Atoi(nodePort)
-				if err != nil {
-					continue
-				}
-				cp.Spec.Ports[k].NodePort = int32(port)
-			}
-			if reflect.DeepEqual(cp, svc) {
-				return svc
-			}
-			if cp.Labels == nil {
-				cp.Labels = make(map[string]string)
+	seen := make(map[types.UID]bool)
+	for _, r := range reports {
+		// 配置文件没有可以记录事件的对象
+		if r.obj == nil {
+			continue
+		}
+		uid := r.obj.GetUID()
+		seen[uid] = true
+		if r.err == nil {
+			if _, ok := s.invalidConfigs[uid]; ok {
+				delete(s.invalidConfigs, uid)
+				s.recordEvent(r.obj, corev1.EventTypeNormal, "ConfigValid", "config is valid and has been applied")
 			}
-			cp.Spec.Type = corev1.ServiceTypeNodePort
-			return cp
+			continue
+		}
+		if s.invalidConfigs[uid] == r.obj.GetResourceVersion() {
+			continue
 		}
+		s.invalidConfigs[uid] = r.obj.GetResourceVersion()
+		message := r.err.Error()
+		if r.fallback {
+			message += ", keep running on the last known-good config"
+		}
+		s.recordEvent(r.obj, corev1.EventTypeWarning, "InvalidConfig", message)
+	}
+	for uid := range s.invalidConfigs {
+		if !seen[uid] {
+			delete(s.invalidConfigs, uid)
+		}
+	}
+}
 
-		sus = append(sus, StatusUpdate{
-			NamespacedName: nn,
-			Resource:       svc,
-			Mutator:        StatusMutatorFunc(mutator),
-		})
+func (s *Server) recordEvent(obj client.Object, eventType, reason, message string) {
+	if s.recorder != nil {
+		s.recorder.Event(obj, eventType, reason, message)
+	}
+}
+
+func (s *Server) rebuild() error {
+	// 提前返回时不保留上一次的宽限期定时
+	s.requeue = 0
+
+	registryConfig, source, err := s.loadConfig()
+	if !errors.Is(err, errConfigNotFound) {
+		s.reportConfigs(source.reports)
+	}
+	// 使用上一次有效的配置时, 状态中保留 Invalid 条件
+	bridgeConfig := source.bridgeConfig
+	if err != nil {
+		if bridgeConfig != nil {
+			s.statusUpdater.Send(bridgeConfigStatusUpdate(bridgeConfig, metav1.Condition{
+				Type:    v1alpha1.ConditionReady,
+				Status:  metav1.ConditionFalse,
+				Reason:  v1alpha1.ReasonInvalid,
+				Message: err.Error(),
+			}, nil, s.opts.ResyncPeriod))
+			bridgeConfig = nil
+		}
+		// 新配置无效时继续使用上一次有效的配置
+		if s.lastConfig == nil || errors.Is(err, errConfigNotFound) {
+			return err
+		}
+		s.logger.Error(err, "invalid config, keep running on the last known-good config")
+		registryConfig = s.lastConfig
+	} else {
+		s.lastConfig = registryConfig
 	}
+	s.requeue = s.cache.NextDrainDeadline(registryConfig.nodeDrainGracePeriod)
 
-	for _, su := range sus {
-		s.statusUpdater.Send(su)
+	for nn := range s.deprecated {
+		if _, ok := s.cache.services[nn]; !ok {
+			delete(s.deprecated, nn)
+		}
+	}
+	for nn := range s.rules {
+		if _, ok := s.cache.services[nn]; !ok {
+			delete(s.rules, nn)
+		}
+	}
+	for nn := range s.rejections {
+		if _, ok := s.cache.services[nn]; !ok {
+			delete(s.rejections, nn)
+		}
 	}
 
+	// 应用命名空间默认值和自动注册规则, 优先级: 服务 > 命名空间 > 规则
+	services := make(map[types.NamespacedName]*corev1.Service)
+	statuses := make(map[types.NamespacedName]map[string]string)
+	ruleServices.Reset()
+	policyRejectedServices.Reset()
+	for nn, svc := range s.cache.services {
+
+		effective := ApplyNamespaceDefaults(svc, s.cache.Namespace(svc.Namespace))
+		effective, rule, err := ApplyRules(effective, s.cache, registryConfig)
+		if err != nil {
+			s.logger.Error(err, "failed to apply rules", "service", nn.String())
+		}
+		ruleName := ""
+		if rule != nil {
+			ruleName = rule.Name
+		}
+		if ruleName != "" {
+			ruleServices.WithLabelValues(ruleName).Inc()
+		}
+		// 生效的规则记录在事件和指标中, 不写入用户的 Service, 避免与 GitOps 工具冲突
+		if previous := s.rules[nn]; previous != ruleName {
+			s.rules[nn] = ruleName
+			s.logger.Info("effective rule changed", "service", nn.String(), "rule", ruleName)
+			if ruleName != "" {
+				s.recordEvent(svc, corev1.EventTypeNormal, "RuleMatched", fmt.Sprintf("auto-registration rule %s applies", ruleName))
+			} else {
+				s.recordEvent(svc, corev1.EventTypeNormal, "RuleUnmatched", fmt.Sprintf("auto-registration rule %s no longer applies", previous))
+			}
+		}
+		services[nn] = effective
+		statuses[nn] = map[string]string{
+			// 清理旧版本写入的注解
+			REGISTRY_EFFECTIVE_RULE:  "",
+			REGISTRY_POLICY_REJECTED: "",
+		}
+
+		if !IsServiceEnabled(effective) {
+			continue
+		}
+		if keys := DeprecatedSettings(svc.Labels, svc.Annotations); len(keys) > 0 && s.deprecated[nn] != svc.ResourceVersion {
+			s.deprecated[nn] = svc.ResourceVersion
+			s.logger.Info("service uses deprecated v1 keys, run `nacosbridge convert` to migrate", "service", nn.String(), "keys", keys)
+		}
+	}
+
+	var buildErr error
+	registryStatuses := make([]v1alpha1.RegistryStatus, 0, len(s.svcRegistry))
+	registrations := make(map[types.NamespacedName]registrationResult)
+	rejections := make(map[types.NamespacedName][]string)
+	failed := make(map[string]error)
 	for _, sr := range s.svcRegistry {
 		ns := registryConfig.WatchNamespace[sr.Name()]
 		selectNamespace := make(map[string]bool)
@@ -182,25 +619,201 @@ func (s *Server) rebuild() error {
 		}
 		if err := sr.Config(GeneratePrefixConfig(sr.Name(), registryConfig.ServiceConfig)); err != nil {
 			s.logger.Error(err, "failed to config", "service", sr.Name())
+			registryStatuses = append(registryStatuses, v1alpha1.RegistryStatus{Name: sr.Name(), Message: err.Error()})
+			for nn, reg := range s.cache.registrations {
+				if RegistrationRegistry(reg) == sr.Name() {
+					registrations[nn] = registrationResult{reason: v1alpha1.ReasonSyncFailed, err: err}
+				}
+			}
 			continue
 		}
 
-		nodeIps := make([]string, 0)
-		for _, node := range s.cache.nodes {
-			for _, address := range node.Status.Addresses {
-				if address.Type == corev1.NodeInternalIP {
-					nodeIps = append(nodeIps, address.Address)
-				}
+		serviceInfos := make([]Service, 0)
+		rejected := 0
+		for nn, svc := range services {
+			infos, err := GenerateServiceInfos(svc, s.cache, selectNamespace, registryConfig)
+			var policyErr *PolicyError
+			if errors.As(err, &policyErr) {
+				// 被拒绝的端口不注册, 其余端口照常注册
+				s.logger.Info("service rejected by policy", "service", nn.String(), "registry", sr.Name(), "rejections", policyErr.Rejections)
+				rejections[nn] = append(rejections[nn], fmt.Sprintf("%s: %v", sr.Name(), policyErr))
+				rejected++
+			} else if err != nil {
+				s.logger.Error(err, "failed to generate service infos", "service", nn.String())
+				continue
 			}
+			serviceInfos = append(serviceInfos, infos...)
 		}
+		policyRejectedServices.WithLabelValues(sr.Name()).Set(float64(rejected))
 
-		serviceInfos := make([]Service, 0)
-		for _, svc := range s.cache.services {
-			serviceInfos = append(serviceInfos, GenerateServiceInfos(svc, nodeIps, selectNamespace)...)
+		// 显式注册与 Service 生成的注册信息一起构建
+		for nn, reg := range s.cache.registrations {
+			if RegistrationRegistry(reg) != sr.Name() {
+				continue
+			}
+			if len(selectNamespace) > 0 && !selectNamespace[reg.Namespace] {
+				registrations[nn] = registrationResult{
+					reason: v1alpha1.ReasonNamespaceNotWatched,
+					err:    fmt.Errorf("namespace %s is not exported to %s", reg.Namespace, sr.Name()),
+				}
+				continue
+			}
+			infos, err := GenerateRegistrationInfos(reg, registryConfig)
+			var nsErr *NamespaceNotAllowedError
+			if errors.As(err, &nsErr) {
+				s.logger.Info("registration namespace not allowed", "registration", nn.String(), "registry", sr.Name(), "reason", err.Error())
+				registrations[nn] = registrationResult{reason: v1alpha1.ReasonNamespaceNotAllowed, err: err}
+				continue
+			} else if err != nil {
+				s.logger.Error(err, "failed to generate registration infos", "registration", nn.String())
+				registrations[nn] = registrationResult{reason: v1alpha1.ReasonInvalid, err: err}
+				continue
+			}
+			infos, rejected, err := evaluateRegistrationPolicies(registryConfig.policies, s.cache.Namespace(reg.Namespace), infos)
+			if err != nil {
+				s.logger.Error(err, "failed to evaluate policies", "registration", nn.String())
+				registrations[nn] = registrationResult{reason: v1alpha1.ReasonInvalid, err: err}
+				continue
+			}
+			if len(rejected) > 0 {
+				s.logger.Info("registration rejected by policy", "registration", nn.String(), "registry", sr.Name(), "ports", slices.Sorted(maps.Keys(rejected)))
+			}
+			registrations[nn] = registrationResult{infos: infos, rejected: rejected}
+			serviceInfos = append(serviceInfos, infos...)
 		}
+
 		if err := sr.Build(serviceInfos); err != nil {
-			return err
+			maps.Copy(failed, failedInstances(err))
+			registryStatuses = append(registryStatuses, v1alpha1.RegistryStatus{Name: sr.Name(), Message: err.Error()})
+			buildErr = err
+			break
 		}
+		now := metav1.Now()
+		registryStatuses = append(registryStatuses, v1alpha1.RegistryStatus{Name: sr.Name(), Connected: true, LastSyncTime: &now})
+	}
+
+	if bridgeConfig != nil {
+		condition := metav1.Condition{
+			Type:    v1alpha1.ConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.ReasonSynced,
+			Message: "all registries are synced",
+		}
+		for _, rs := range registryStatuses {
+			if !rs.Connected {
+				condition.Status = metav1.ConditionFalse
+				condition.Reason = v1alpha1.ReasonSyncFailed
+				condition.Message = fmt.Sprintf("%s: %s", rs.Name, rs.Message)
+				break
+			}
+		}
+		s.statusUpdater.Send(bridgeConfigStatusUpdate(bridgeConfig, condition, registryStatuses, s.opts.ResyncPeriod))
+	}
+
+	s.reportRejections(rejections)
+
+	for nn, result := range registrations {
+		s.statusUpdater.Send(registrationStatusUpdate(s.cache.registrations[nn], result, failed))
+	}
+
+	for nn, svc := range s.cache.services {
+		su, ok := serviceStatusUpdate(nn, svc, IsServiceEnabled(services[nn]), statuses[nn])
+		if ok {
+			s.statusUpdater.Send(su)
+		}
+	}
+	return buildErr
+}
+
+// reportRejections 在服务被策略拒绝的原因变化时记录事件, 不写入用户的 Service, 避免与 GitOps 工具冲突
+func (s *Server) reportRejections(rejections map[types.NamespacedName][]string) {
+	for nn, svc := range s.cache.services {
+		slices.Sort(rejections[nn])
+		message := strings.Join(rejections[nn], "; ")
+		previous := s.rejections[nn]
+		if previous == message {
+			continue
+		}
+		if message != "" {
+			s.rejections[nn] = message
+			s.recordEvent(svc, corev1.EventTypeWarning, "PolicyRejected", message)
+		} else {
+			delete(s.rejections, nn)
+			s.recordEvent(svc, corev1.EventTypeNormal, "PolicyAllowed", "no longer rejected by policy")
+		}
+	}
+}
+
+// serviceStatusUpdate 生成回写服务的更新, 服务未开启注册且没有遗留的状态注解时不需要更新
+func serviceStatusUpdate(nn types.NamespacedName, svc *corev1.Service, enabled bool, status map[string]string) (StatusUpdate, bool) {
+	if !enabled {
+		stale := false
+		for k := range status {
+			if _, ok := svc.Annotations[k]; ok {
+				stale = true
+			}
+		}
+		if !stale {
+			return StatusUpdate{}, false
+		}
+	}
+
+	mutator := func(obj client.Object) client.Object {
+		svc := statusAnnotationMutator(status)(obj).(*corev1.Service)
+		if !enabled || GetSetting(svc, REGISTRY_SERVICE_EXTERNAL) != "true" {
+			return svc
+		}
+		cp := svc.DeepCopy()
+		for k, specPort := range cp.Spec.Ports {
+			nodePort, ok := LookupSetting(svc, REGISTRY_PORT_NODEPORT+specPort.Name)
+			if !ok {
+				// v1 的 portname- 在 external 服务中同时表示 NodePort
+				nodePort, ok = lookupKey(svc, REGISTRY_PORTNAME+specPort.Name)
+			}
+			if !ok {
+				continue
+			}
+			port, err := strconv.Atoi(nodePort)
+			if err != nil {
+				continue
+			}
+			cp.Spec.Ports[k].NodePort = int32(port)
+		}
+		if reflect.DeepEqual(cp, svc) {
+			return svc
+		}
+		if cp.Labels == nil {
+			cp.Labels = make(map[string]string)
+		}
+		cp.Spec.Type = corev1.ServiceTypeNodePort
+		return cp
+	}
+
+	return StatusUpdate{
+		NamespacedName: nn,
+		Resource:       svc,
+		Mutator:        StatusMutatorFunc(mutator),
+	}, true
+}
+
+// statusAnnotationMutator 写入服务注解, 值为空时删除注解
+func statusAnnotationMutator(status map[string]string) func(client.Object) client.Object {
+	return func(obj client.Object) client.Object {
+		svc := obj.(*corev1.Service)
+		cp := svc.DeepCopy()
+		for k, v := range status {
+			if v == "" {
+				delete(cp.Annotations, k)
+				continue
+			}
+			if cp.Annotations == nil {
+				cp.Annotations = make(map[string]string)
+			}
+			cp.Annotations[k] = v
+		}
+		if reflect.DeepEqual(cp, svc) {
+			return svc
+		}
+		return cp
 	}
-	return nil
 }
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRebuildResetsRequeue(t *testing.T) {
+	s := NewService(nil, ServerOptions{})
+	s.requeue = time.Minute
+	if err := s.rebuild(); err == nil {
+		t.Fatal("expected rebuild to fail without a config")
+	}
+	if s.requeue != 0 {
+		t.Errorf("requeue = %v, want 0 after a failed rebuild", s.requeue)
+	}
+}
+
+func TestReportRejections(t *testing.T) {
+	s := NewService(nil, ServerOptions{})
+	recorder := record.NewFakeRecorder(10)
+	s.recorder = recorder
+	nn := types.NamespacedName{Namespace: "default", Name: "user"}
+	s.cache.services = map[types.NamespacedName]*corev1.Service{
+		nn: {ObjectMeta: metav1.ObjectMeta{Namespace: nn.Namespace, Name: nn.Name}},
+	}
+	rejections := map[types.NamespacedName][]string{nn: {"nacos: rejected by policy: user:22 no-ssh: port.port != 22"}}
+
+	s.reportRejections(rejections)
+	if got := <-recorder.Events; !strings.HasPrefix(got, "Warning PolicyRejected nacos: rejected by policy") {
+		t.Errorf("event = %q, want a PolicyRejected warning", got)
+	}
+
+	// 原因不变时不重复记录
+	s.reportRejections(rejections)
+	if len(recorder.Events) != 0 {
+		t.Errorf("expected no event for an unchanged rejection, got %q", <-recorder.Events)
+	}
+
+	s.reportRejections(nil)
+	if got := <-recorder.Events; !strings.HasPrefix(got, "Normal PolicyAllowed") {
+		t.Errorf("event = %q, want a PolicyAllowed event", got)
+	}
+	if _, ok := s.rejections[nn]; ok {
+		t.Error("expected the rejection to be cleared")
+	}
+}
+
+func TestScheduleDelay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		deadlines []time.Time
+		max       time.Duration
+	}{
+		{name: "no deadline", max: time.Second},
+		{name: "later deadline", deadlines: []time.Time{now.Add(time.Minute)}, max: time.Second},
+		// 失败的重建后设置的宽限期定时早于防抖延迟时不被推迟
+		{name: "earlier requeue", deadlines: []time.Time{{}, now.Add(100 * time.Millisecond)}, max: 100 * time.Millisecond},
+		{name: "expired deadline", deadlines: []time.Time{now.Add(-time.Second)}, max: 0},
+	}
+	for _, tt := range tests {
+		if got := scheduleDelay(time.Second, tt.deadlines...); got > tt.max {
+			t.Errorf("%s: delay = %v, want at most %v", tt.name, got, tt.max)
+		}
+	}
+}
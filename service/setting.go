@@ -0,0 +1,171 @@
+package service
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 服务配置可以写在标签或注解中, 注解优先于标签
+// 标签值不能包含逗号、斜杠且长度不能超过 63 个字符, 这些值只能通过注解配置
+// 只有开启注册的 nacosbridge.io/service 必须是标签, 以便按标签筛选
+//
+// v2 键名优先于 v1 键名, v1 键名仍然可用但会记录废弃警告
+
+// v2 键名 -> v1 键名
+var deprecatedKeys = map[string]string{
+	REGISTRY_GATEWAY_DOMAIN: REGISTRY_SERVICE_DOMIN,
+}
+
+// v2 前缀 -> v1 前缀
+var deprecatedPrefixes = map[string]string{
+	REGISTRY_PORT_SERVICE:  REGISTRY_PORTNAME,
+	REGISTRY_GATEWAY_PORT:  REGISTRY_SERVICE_DOMIN_PORT,
+	SERVICE_METADATA + ".": SERVICE_MATEDATA + ".",
+}
+
+// v1Key 返回 v2 键名对应的 v1 键名
+func v1Key(key string) (string, bool) {
+	if old, ok := deprecatedKeys[key]; ok {
+		return old, true
+	}
+	for prefix, old := range deprecatedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return old + strings.TrimPrefix(key, prefix), true
+		}
+	}
+	return "", false
+}
+
+// v2Key 返回 v1 键名对应的 v2 键名
+func v2Key(key string) (string, bool) {
+	for newKey, old := range deprecatedKeys {
+		if key == old {
+			return newKey, true
+		}
+	}
+	for prefix, old := range deprecatedPrefixes {
+		if strings.HasPrefix(key, old) {
+			return prefix + strings.TrimPrefix(key, old), true
+		}
+	}
+	return "", false
+}
+
+func lookupKey(svc *corev1.Service, key string) (string, bool) {
+	if value, ok := svc.Annotations[key]; ok {
+		return value, true
+	}
+	value, ok := svc.Labels[key]
+	return value, ok
+}
+
+// LookupSetting 读取服务配置, 注解优先于标签, v2 键名优先于 v1 键名
+func LookupSetting(svc *corev1.Service, key string) (string, bool) {
+	if value, ok := lookupKey(svc, key); ok {
+		return value, true
+	}
+	if old, ok := v1Key(key); ok {
+		return lookupKey(svc, old)
+	}
+	return "", false
+}
+
+// GetSetting 读取服务配置, 不存在时返回空字符串
+func GetSetting(svc *corev1.Service, key string) string {
+	value, _ := LookupSetting(svc, key)
+	return value
+}
+
+// GetSettingsWithPrefix 读取指定前缀的所有服务配置, 返回去掉前缀后的键
+func GetSettingsWithPrefix(svc *corev1.Service, prefix string) map[string]string {
+	prefixes := []string{prefix}
+	if old, ok := deprecatedPrefixes[prefix]; ok {
+		prefixes = []string{old, prefix}
+	}
+
+	settings := make(map[string]string)
+	for _, p := range prefixes {
+		for _, source := range []map[string]string{svc.Labels, svc.Annotations} {
+			for k, v := range source {
+				if strings.HasPrefix(k, p) {
+					settings[strings.TrimPrefix(k, p)] = v
+				}
+			}
+		}
+	}
+	return settings
+}
+
+// ServiceMetadata 读取服务级元数据注解
+func ServiceMetadata(svc *corev1.Service) map[string]string {
+	metadata := GeneratePrefixConfig(SERVICE_MATEDATA, svc.Annotations)
+	maps.Copy(metadata, GeneratePrefixConfig(SERVICE_METADATA, svc.Annotations))
+	maps.DeleteFunc(metadata, func(k, _ string) bool {
+		return strings.HasPrefix(k, SERVICE_PORT_METADATA)
+	})
+	return metadata
+}
+
+// ServicePortMetadata 合并端口级元数据注解, 键名为 nacosbridge.io/metadata.port-<portName>.<key>
+func ServicePortMetadata(svc *corev1.Service, portName string) map[string]string {
+	if portName == "" {
+		return make(map[string]string)
+	}
+	metadata := GeneratePrefixConfig(SERVICE_MATEDATA+"."+SERVICE_PORT_METADATA+portName, svc.Annotations)
+	maps.Copy(metadata, GeneratePrefixConfig(SERVICE_METADATA+"."+SERVICE_PORT_METADATA+portName, svc.Annotations))
+	return metadata
+}
+
+// IsServiceEnabled 判断服务是否开启注册
+func IsServiceEnabled(svc *corev1.Service) bool {
+	if GetSetting(svc, REGISTRY_ENABLED) == "false" {
+		return false
+	}
+	_, ok := svc.Labels[REGISTRY_SERVICE_NAME]
+	return ok
+}
+
+// DeprecatedSettings 返回服务中使用的 v1 键名
+func DeprecatedSettings(labels, annotations map[string]string) []string {
+	keys := make([]string, 0)
+	for _, source := range []map[string]string{labels, annotations} {
+		for k := range source {
+			if _, ok := v2Key(k); ok && !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ConvertSettings 将 v1 键名改写为 v2 键名, 已存在的 v2 键保持不变
+// external 服务中值为数字的 portname- 表示 NodePort, 改写为 nodeport-
+func ConvertSettings(labels, annotations map[string]string) bool {
+	external := annotations[REGISTRY_SERVICE_EXTERNAL] == "true"
+	if _, ok := annotations[REGISTRY_SERVICE_EXTERNAL]; !ok {
+		external = labels[REGISTRY_SERVICE_EXTERNAL] == "true"
+	}
+	changed := false
+	for _, source := range []map[string]string{labels, annotations} {
+		for k, v := range source {
+			newKey, ok := v2Key(k)
+			if !ok {
+				continue
+			}
+			if _, err := strconv.Atoi(v); err == nil && external && strings.HasPrefix(k, REGISTRY_PORTNAME) {
+				newKey = REGISTRY_PORT_NODEPORT + strings.TrimPrefix(k, REGISTRY_PORTNAME)
+			}
+			if _, exists := source[newKey]; !exists {
+				source[newKey] = v
+			}
+			delete(source, k)
+			changed = true
+		}
+	}
+	return changed
+}
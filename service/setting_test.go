@@ -0,0 +1,62 @@
+package service
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestConvertSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantLabels  map[string]string
+	}{
+		{
+			name:       "port service name",
+			labels:     map[string]string{REGISTRY_PORTNAME + "http": "user-http"},
+			wantLabels: map[string]string{REGISTRY_PORT_SERVICE + "http": "user-http"},
+		},
+		{
+			name:       "nodeport of an external service",
+			labels:     map[string]string{REGISTRY_SERVICE_EXTERNAL: "true", REGISTRY_PORTNAME + "http": "30080"},
+			wantLabels: map[string]string{REGISTRY_SERVICE_EXTERNAL: "true", REGISTRY_PORT_NODEPORT + "http": "30080"},
+		},
+		{
+			name:       "numeric name of a cluster service",
+			labels:     map[string]string{REGISTRY_PORTNAME + "http": "30080"},
+			wantLabels: map[string]string{REGISTRY_PORT_SERVICE + "http": "30080"},
+		},
+		{
+			name:       "existing v2 key wins",
+			labels:     map[string]string{REGISTRY_PORTNAME + "http": "old", REGISTRY_PORT_SERVICE + "http": "new"},
+			wantLabels: map[string]string{REGISTRY_PORT_SERVICE + "http": "new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := tt.annotations
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			if !ConvertSettings(tt.labels, annotations) {
+				t.Fatal("expected settings to be converted")
+			}
+			if !maps.Equal(tt.labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", tt.labels, tt.wantLabels)
+			}
+		})
+	}
+}
+
+func TestGeneratePrefixConfig(t *testing.T) {
+	config := map[string]string{
+		"nacos.address": "nacos-server",
+		"nacosport":     "8848",
+		"eureka.port":   "8761",
+	}
+	got := GeneratePrefixConfig("nacos", config)
+	if want := map[string]string{"address": "nacos-server"}; !maps.Equal(got, want) {
+		t.Errorf("GeneratePrefixConfig = %v, want %v", got, want)
+	}
+}
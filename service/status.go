@@ -4,6 +4,8 @@ import (
 	"context"
 	"reflect"
 
+	"nacosbridge/api/v1alpha1"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
@@ -78,9 +80,9 @@ func (suh *StatusUpdateHandler) apply(upd StatusUpdate) {
 
 		newObj := upd.Mutator.Mutate(obj)
 
+		// 规格不变时跳过 Update, 但仍需回写状态
 		if isSpecEqual(obj, newObj) {
-			log.Log.Info("skip update no-op", "name", upd.NamespacedName.Name, "namespace", upd.NamespacedName.Namespace, "kind", kind)
-			return nil
+			log.Log.Info("skip spec update no-op", "name", upd.NamespacedName.Name, "namespace", upd.NamespacedName.Namespace, "kind", kind)
 		} else {
 			if err := suh.client.Update(context.Background(), newObj); err != nil {
 				log.Log.Error(err, "updated status failed", "name", upd.NamespacedName.Name, "namespace", upd.NamespacedName.Namespace, "kind", kind)
@@ -183,6 +185,14 @@ func isStatusEqual(objA, objB interface{}) bool {
 				return true
 			}
 		}
+	case *v1alpha1.NacosBridgeConfig:
+		if b, ok := objB.(*v1alpha1.NacosBridgeConfig); ok {
+			return reflect.DeepEqual(a.Status, b.Status)
+		}
+	case *v1alpha1.NacosRegistration:
+		if b, ok := objB.(*v1alpha1.NacosRegistration); ok {
+			return reflect.DeepEqual(a.Status, b.Status)
+		}
 	default:
 		return reflect.DeepEqual(objA, objB)
 	}
@@ -202,6 +212,19 @@ func isSpecEqual(objA, objB interface{}) bool {
 				return true
 			}
 		}
+	case *v1alpha1.NacosBridgeConfig:
+		// 只回写状态, 规格和元数据不变时跳过 Update
+		if b, ok := objB.(*v1alpha1.NacosBridgeConfig); ok {
+			return reflect.DeepEqual(a.Spec, b.Spec) &&
+				reflect.DeepEqual(a.Labels, b.Labels) &&
+				reflect.DeepEqual(a.Annotations, b.Annotations)
+		}
+	case *v1alpha1.NacosRegistration:
+		if b, ok := objB.(*v1alpha1.NacosRegistration); ok {
+			return reflect.DeepEqual(a.Spec, b.Spec) &&
+				reflect.DeepEqual(a.Labels, b.Labels) &&
+				reflect.DeepEqual(a.Annotations, b.Annotations)
+		}
 	default:
 		return reflect.DeepEqual(objA, objB)
 	}
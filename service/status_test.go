@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"nacosbridge/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newStatusTestHandler(t *testing.T, objs ...client.Object) *StatusUpdateHandler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&v1alpha1.NacosBridgeConfig{}, &v1alpha1.NacosRegistration{}).
+		Build()
+	suh := NewStatusUpdateHandler()
+	if err := suh.InjectClient(c); err != nil {
+		t.Fatal(err)
+	}
+	return suh
+}
+
+func TestApplyRegistrationStatus(t *testing.T) {
+	reg := &v1alpha1.NacosRegistration{
+		ObjectMeta: metav1.ObjectMeta{Name: "vm", Namespace: "default", Generation: 1},
+		Spec: v1alpha1.NacosRegistrationSpec{
+			ServiceName: "vm",
+			Instances:   []v1alpha1.RegistrationInstance{{IP: "10.0.0.1", Port: 8080}},
+		},
+	}
+	suh := newStatusTestHandler(t, reg)
+
+	suh.apply(registrationStatusUpdate(reg, registrationResult{}, nil))
+
+	got := &v1alpha1.NacosRegistration{}
+	if err := suh.client.Get(context.Background(), types.NamespacedName{Name: "vm", Namespace: "default"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, v1alpha1.ConditionReady) {
+		t.Fatalf("expected Ready condition to be saved, got %+v", got.Status.Conditions)
+	}
+	if len(got.Status.Instances) != 1 || !meta.IsStatusConditionTrue(got.Status.Instances[0].Conditions, v1alpha1.ConditionRegistered) {
+		t.Fatalf("expected Registered instance condition to be saved, got %+v", got.Status.Instances)
+	}
+	if got.Status.ObservedGeneration != 1 {
+		t.Fatalf("expected observedGeneration 1, got %d", got.Status.ObservedGeneration)
+	}
+}
+
+func TestApplyBridgeConfigStatus(t *testing.T) {
+	bc := &v1alpha1.NacosBridgeConfig{ObjectMeta: metav1.ObjectMeta{Name: "default", Generation: 2}}
+	suh := newStatusTestHandler(t, bc)
+
+	suh.apply(bridgeConfigStatusUpdate(&BridgeConfig{NacosBridgeConfig: bc}, metav1.Condition{
+		Type:    v1alpha1.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  v1alpha1.ReasonInvalid,
+		Message: "invalid config",
+	}, nil, 0))
+
+	got := &v1alpha1.NacosBridgeConfig{}
+	if err := suh.client.Get(context.Background(), types.NamespacedName{Name: "default"}, got); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionReady)
+	if condition == nil || condition.Reason != v1alpha1.ReasonInvalid {
+		t.Fatalf("expected Invalid Ready condition to be saved, got %+v", got.Status.Conditions)
+	}
+}
+
+func TestBridgeConfigStatusSyncTime(t *testing.T) {
+	synced := metav1.Condition{
+		Type:    v1alpha1.ConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.ReasonSynced,
+		Message: "all registries are synced",
+	}
+	saved := synced
+	saved.ObservedGeneration = 1
+	last := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	bc := &v1alpha1.NacosBridgeConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Generation: 1},
+		Status: v1alpha1.NacosBridgeConfigStatus{
+			ObservedGeneration: 1,
+			LastSyncTime:       &last,
+			Registries:         []v1alpha1.RegistryStatus{{Name: "nacos", Connected: true, LastSyncTime: &last}},
+			Conditions:         []metav1.Condition{saved},
+		},
+	}
+	mutate := func(obj *v1alpha1.NacosBridgeConfig, condition metav1.Condition, registries []v1alpha1.RegistryStatus, interval time.Duration) *v1alpha1.NacosBridgeConfig {
+		update := bridgeConfigStatusUpdate(&BridgeConfig{NacosBridgeConfig: obj}, condition, registries, interval)
+		return update.Mutator.Mutate(obj.DeepCopy()).(*v1alpha1.NacosBridgeConfig)
+	}
+	now := metav1.Now()
+	connected := []v1alpha1.RegistryStatus{{Name: "nacos", Connected: true, LastSyncTime: &now}}
+
+	// 结果不变且未超过间隔时保留同步时间, 状态不需要写入
+	got := mutate(bc, synced, connected, time.Hour)
+	if !got.Status.LastSyncTime.Equal(&last) || !got.Status.Registries[0].LastSyncTime.Equal(&last) {
+		t.Errorf("expected sync times to be kept, got %v and %v", got.Status.LastSyncTime, got.Status.Registries[0].LastSyncTime)
+	}
+	if !isStatusEqual(bc, got) {
+		t.Error("expected an unchanged sync result to need no status write")
+	}
+
+	// 超过间隔后更新
+	got = mutate(bc, synced, connected, time.Second)
+	if got.Status.LastSyncTime.Equal(&last) || got.Status.Registries[0].LastSyncTime.Equal(&last) {
+		t.Error("expected sync times to be refreshed after the interval")
+	}
+
+	// 结果变化时立即更新
+	failed := []v1alpha1.RegistryStatus{{Name: "nacos", Message: "connection refused"}}
+	got = mutate(bc, metav1.Condition{
+		Type:    v1alpha1.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  v1alpha1.ReasonSyncFailed,
+		Message: "nacos: connection refused",
+	}, failed, time.Hour)
+	got = mutate(got, synced, connected, time.Hour)
+	if got.Status.LastSyncTime.Equal(&last) {
+		t.Error("expected the sync time to be refreshed when the condition changes")
+	}
+	if got.Status.Registries[0].LastSyncTime.Equal(&last) {
+		t.Error("expected the registry sync time to be refreshed when it reconnects")
+	}
+}
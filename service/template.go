@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TemplateData 模板渲染时可用的数据
+type TemplateData struct {
+	Name          string
+	Namespace     string
+	Labels        map[string]string
+	Annotations   map[string]string
+	ClusterName   string
+	ClusterDomain string
+	ClusterIP     string
+	ClusterIPs    []string
+	Port          corev1.ServicePort
+	Service       *corev1.Service
+}
+
+func NewTemplateData(svc *corev1.Service, port corev1.ServicePort, config *Config) TemplateData {
+	return TemplateData{
+		Name:          svc.Name,
+		Namespace:     svc.Namespace,
+		Labels:        svc.Labels,
+		Annotations:   svc.Annotations,
+		ClusterName:   config.ClusterName,
+		ClusterDomain: config.GetClusterDomain(),
+		ClusterIP:     svc.Spec.ClusterIP,
+		ClusterIPs:    svc.Spec.ClusterIPs,
+		Port:          port,
+		Service:       svc,
+	}
+}
+
+// RenderTemplate 渲染 Go 模板, 缺失的字段视为错误
+func RenderTemplate(text string, data TemplateData) (string, error) {
+	tmpl, err := template.New("nacosbridge").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %q: %v", text, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %v", text, err)
+	}
+	return buf.String(), nil
+}
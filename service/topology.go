@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// 使用节点的 topology.kubernetes.io/zone 作为 Nacos 集群名称
+	TOPOLOGY_ZONE = "zone"
+
+	// 使用节点的 topology.kubernetes.io/region 作为 Nacos 集群名称
+	TOPOLOGY_REGION = "region"
+
+	// 不设置 Nacos 集群名称
+	TOPOLOGY_NONE = "none"
+)
+
+// applyTopology 按节点的拓扑标签设置 NodePort 实例的 Nacos 集群名称和 zone/region 元数据
+func applyTopology(svc *corev1.Service, cache *Cache, nodeSelector *NodeSelector, families []corev1.IPFamily, config *Config, serviceInfos []Service) error {
+	mode := config.TopologyCluster
+	if v := GetSetting(svc, REGISTRY_TOPOLOGY_CLUSTER); v != "" {
+		mode = v
+	}
+	switch mode {
+	case "", TOPOLOGY_NONE, TOPOLOGY_ZONE, TOPOLOGY_REGION:
+	default:
+		return fmt.Errorf("invalid topology cluster %q, must be %s, %s or %s", mode, TOPOLOGY_ZONE, TOPOLOGY_REGION, TOPOLOGY_NONE)
+	}
+	zoneMetadata := autoMetadataEnabled(config, METADATA_K8S_ZONE)
+	regionMetadata := autoMetadataEnabled(config, METADATA_K8S_REGION)
+	if (mode == "" || mode == TOPOLOGY_NONE) && !zoneMetadata && !regionMetadata {
+		return nil
+	}
+
+	for _, node := range cache.nodes {
+		zone := node.Labels[corev1.LabelTopologyZone]
+		region := node.Labels[corev1.LabelTopologyRegion]
+		if zone == "" && region == "" {
+			continue
+		}
+
+		override := InstanceOverride{Metadata: make(map[string]string)}
+		switch mode {
+		case TOPOLOGY_ZONE:
+			override.ClusterName = zone
+		case TOPOLOGY_REGION:
+			override.ClusterName = region
+		}
+		if zoneMetadata && zone != "" {
+			override.Metadata[METADATA_K8S_ZONE] = zone
+		}
+		if regionMetadata && region != "" {
+			override.Metadata[METADATA_K8S_REGION] = region
+		}
+
+		for _, address := range FilterAddresses(nodeSelector.Addresses(node), families) {
+			for i := range serviceInfos {
+				if !slices.Contains(serviceInfos[i].IP, address) {
+					continue
+				}
+				serviceInfos[i].setOverride(address, func(o *InstanceOverride) {
+					o.ClusterName = override.ClusterName
+					o.Metadata = override.Metadata
+				})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// 各注册中心支持的 service_config 键
+var registrySettings = map[string][]string{
+	(&Nacos{}).Name(): {"address", "port", "username", "password"},
+}
+
+// 可以开启的自动元数据键
+var autoMetadataKeys = []string{
+	AUTO_METADATA_ALL,
+	METADATA_K8S_CLUSTER,
+	METADATA_K8S_NAMESPACE,
+	METADATA_K8S_SERVICE,
+	METADATA_K8S_UID,
+	METADATA_K8S_PORT_NAME,
+	METADATA_K8S_PROTOCOL,
+	METADATA_K8S_APP_PROTOCOL,
+	METADATA_K8S_SERVICE_TYPE,
+	METADATA_BRIDGE_VERSION,
+	METADATA_K8S_ZONE,
+	METADATA_K8S_REGION,
+}
+
+// ValidationError 配置校验失败, 包含全部错误
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("invalid config: %s", e.Errors[0])
+	}
+	return fmt.Sprintf("invalid config, %d errors: %s", len(e.Errors), strings.Join(e.Errors, "; "))
+}
+
+// validate 校验配置的取值, 返回全部错误
+func (c *Config) validate() []string {
+	errs := make([]string, 0)
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	for key, value := range c.ServiceConfig {
+		registry, setting, ok := strings.Cut(key, ".")
+		keys, known := registrySettings[registry]
+		switch {
+		case !ok || !known:
+			addErr("service_config.%s: unknown registry %q", key, registry)
+			continue
+		case !slices.Contains(keys, setting):
+			addErr("service_config.%s: unknown setting%s", key, suggest(setting, keys))
+			continue
+		}
+		if setting == "port" {
+			if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+				addErr("service_config.%s: invalid port %q", key, value)
+			}
+		}
+	}
+	for registry := range registrySettings {
+		if _, ok := c.ServiceConfig[registry+".address"]; !ok {
+			addErr("service_config.%s.address: required", registry)
+		}
+	}
+	for registry := range c.WatchNamespace {
+		if _, ok := registrySettings[registry]; !ok {
+			addErr("watch_namespace.%s: unknown registry", registry)
+		}
+	}
+
+	switch c.AddressType {
+	case "", ADDRESS_TYPE_FQDN, ADDRESS_TYPE_SHORT, ADDRESS_TYPE_CLUSTERIP:
+	case ADDRESS_TYPE_TEMPLATE:
+		if c.AddressTemplate == "" {
+			addErr("address_template: required when address_type is %s", ADDRESS_TYPE_TEMPLATE)
+		}
+	default:
+		addErr("address_type: unknown value %q, must be %s, %s, %s or %s", c.AddressType, ADDRESS_TYPE_FQDN, ADDRESS_TYPE_SHORT, ADDRESS_TYPE_CLUSTERIP, ADDRESS_TYPE_TEMPLATE)
+	}
+	switch corev1.NodeAddressType(c.NodeAddressType) {
+	case "", corev1.NodeInternalIP, corev1.NodeExternalIP, corev1.NodeHostName:
+	default:
+		addErr("node_address_type: unknown value %q, must be %s, %s or %s", c.NodeAddressType, corev1.NodeInternalIP, corev1.NodeExternalIP, corev1.NodeHostName)
+	}
+	switch c.IPFamily {
+	case "", IP_FAMILY_IPV4, IP_FAMILY_IPV6, IP_FAMILY_BOTH:
+	default:
+		addErr("ip_family: unknown value %q, must be %s, %s or %s", c.IPFamily, IP_FAMILY_IPV4, IP_FAMILY_IPV6, IP_FAMILY_BOTH)
+	}
+	switch c.TopologyCluster {
+	case "", TOPOLOGY_ZONE, TOPOLOGY_REGION, TOPOLOGY_NONE:
+	default:
+		addErr("topology_cluster: unknown value %q, must be %s, %s or %s", c.TopologyCluster, TOPOLOGY_ZONE, TOPOLOGY_REGION, TOPOLOGY_NONE)
+	}
+	if c.NodeSelector != "" {
+		if _, err := labels.Parse(c.NodeSelector); err != nil {
+			addErr("node_selector: %v", err)
+		}
+	}
+
+	templates := map[string]string{
+		"address_template":      c.AddressTemplate,
+		"service_name_template": c.ServiceNameTemplate,
+		"group_template":        c.GroupTemplate,
+	}
+	for k, text := range c.MetadataTemplates {
+		templates["metadata_templates."+k] = text
+	}
+	for field, text := range templates {
+		if _, err := template.New("nacosbridge").Parse(text); err != nil {
+			addErr("%s: %v", field, err)
+		}
+	}
+	for _, key := range c.AutoMetadata {
+		if !slices.Contains(autoMetadataKeys, key) {
+			addErr("auto_metadata: unknown key %q%s", key, suggest(key, autoMetadataKeys))
+		}
+	}
+
+	errs = append(errs, c.validateNamespaceMapping()...)
+	errs = append(errs, c.validateRules()...)
+	for i, p := range c.Policies {
+		if p.Expression == "" {
+			addErr("policies[%d].expression: required", i)
+		}
+	}
+
+	slices.Sort(errs)
+	return errs
+}
+
+// validateNamespaceMapping 校验命名空间映射, 团队配置单独校验
+func (c *Config) validateNamespaceMapping() []string {
+	errs := make([]string, 0)
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	for i, entry := range c.NamespaceMapping.Namespaces {
+		if entry.Namespace == "" || entry.NacosNamespace == "" {
+			addErr("namespace_mapping.namespaces[%d]: namespace and nacos_namespace are required", i)
+		}
+		if _, err := path.Match(entry.Namespace, ""); err != nil {
+			addErr("namespace_mapping.namespaces[%d].namespace: invalid pattern %q", i, entry.Namespace)
+		}
+	}
+	return errs
+}
+
+// validateRules 校验自动注册规则, 团队配置单独校验
+func (c *Config) validateRules() []string {
+	errs := make([]string, 0)
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	for i, r := range c.Rules {
+		for _, pattern := range r.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				addErr("rules[%d].namespaces: invalid pattern %q", i, pattern)
+			}
+		}
+		for field, selector := range map[string]string{"namespace_selector": r.NamespaceSelector, "selector": r.Selector} {
+			if _, err := labels.Parse(selector); err != nil {
+				addErr("rules[%d].%s: %v", i, field, err)
+			}
+		}
+		switch r.ServiceType {
+		case "", "cluster", "external", "gateway":
+		default:
+			addErr("rules[%d].service_type: unknown value %q, must be cluster, external or gateway", i, r.ServiceType)
+		}
+	}
+	return errs
+}
+
+// unknownFields 返回 JSON 中结构体未定义的键
+func unknownFields(value interface{}, t reflect.Type, prefix string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	errs := make([]string, 0)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return errs
+		}
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for key, inner := range v {
+			ft, ok := fields[key]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s%s: unknown key%s", prefix, key, suggest(key, slices.Sorted(maps.Keys(fields)))))
+				continue
+			}
+			errs = append(errs, unknownFields(inner, ft, prefix+key+".")...)
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice {
+			return errs
+		}
+		for i, inner := range v {
+			errs = append(errs, unknownFields(inner, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i))...)
+		}
+	}
+	return errs
+}
+
+// suggest 返回与 key 最接近的候选键提示, 用于发现拼写错误
+func suggest(key string, candidates []string) string {
+	best, distance := "", 3
+	for _, candidate := range candidates {
+		if d := editDistance(key, candidate); d < distance {
+			best, distance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// decodeStrict 解析配置并返回未知键和类型错误, 不是合法的 JSON 时返回 false
+func (c *Config) decodeStrict(content string) ([]string, bool) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(content), &raw); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}, false
+	}
+	errs := unknownFields(raw, reflect.TypeOf(c), "")
+	if err := json.Unmarshal([]byte(content), c); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			errs = append(errs, fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value))
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+	slices.Sort(errs)
+	return errs, true
+}
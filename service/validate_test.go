@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"watch_namespace", "service_config", "cluster_domain"}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "watch_namspace", want: `, did you mean "watch_namespace"?`},
+		{key: "service_confg", want: `, did you mean "service_config"?`},
+		{key: "cluster_domian", want: `, did you mean "cluster_domain"?`},
+		{key: "something_else", want: ""},
+		{key: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.key, candidates); got != tt.want {
+			t.Errorf("suggest(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"abc", "abc", 0},
+		{"abc", "abd", 1},
+		{"abc", "acb", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		content map[string]interface{}
+		want    []string
+	}{
+		{
+			name:    "known keys",
+			content: map[string]interface{}{"cluster_domain": "a", "service_config": map[string]interface{}{"nacos.address": "a"}},
+			want:    []string{},
+		},
+		{
+			name:    "unknown top-level key",
+			content: map[string]interface{}{"watch_namspace": map[string]interface{}{}},
+			want:    []string{`watch_namspace: unknown key, did you mean "watch_namespace"?`},
+		},
+		{
+			name: "unknown nested key",
+			content: map[string]interface{}{"namespace_mapping": map[string]interface{}{
+				"identiy": true,
+			}},
+			want: []string{`namespace_mapping.identiy: unknown key, did you mean "identity"?`},
+		},
+		{
+			name: "unknown key in a list",
+			content: map[string]interface{}{"rules": []interface{}{
+				map[string]interface{}{"name": "a"},
+				map[string]interface{}{"name": "b", "selecter": "app=b"},
+			}},
+			want: []string{`rules[1].selecter: unknown key, did you mean "selector"?`},
+		},
+		{
+			name:    "maps of strings are not checked",
+			content: map[string]interface{}{"metadata": map[string]interface{}{"anything": "value"}},
+			want:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unknownFields(tt.content, reflect.TypeOf(&Config{}), "")
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unknownFields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+		want    []string
+	}{
+		{
+			name:    "valid",
+			content: `{"cluster_domain": "cluster.local"}`,
+			ok:      true,
+			want:    []string{},
+		},
+		{
+			name:    "invalid JSON",
+			content: `{"cluster_domain": `,
+			ok:      false,
+			want:    []string{"invalid JSON:"},
+		},
+		{
+			name:    "type error",
+			content: `{"include_tainted_nodes": "yes"}`,
+			ok:      true,
+			want:    []string{"include_tainted_nodes: expected bool, got string"},
+		},
+		{
+			name:    "unknown key and type error",
+			content: `{"clustr_domain": "a", "metadata": ["a"]}`,
+			ok:      true,
+			want:    []string{"clustr_domain: unknown key", "metadata: expected map[string]string, got array"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, ok := (&Config{}).decodeStrict(tt.content)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors = %q, want %q", errs, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(errs[i], want) {
+					t.Errorf("errors[%d] = %q, want prefix %q", i, errs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCollectsAllErrors(t *testing.T) {
+	err := (&Config{}).Load(`{"watch_namspace": {}, "service_config": {"nacos.address": "a", "nacos.port": "88480"}, "address_type": "ip"}`)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	if len(validationErr.Errors) != 3 {
+		t.Errorf("errors = %q, want 3 errors", validationErr.Errors)
+	}
+}
+
+func TestReportConfigsCountsValidationErrors(t *testing.T) {
+	s := NewService(nil, ServerOptions{})
+	s.reportConfigs([]configReport{
+		{err: &ValidationError{Errors: []string{"a", "b"}}},
+		{err: errors.New("failed to get secret")},
+	})
+	if got := testutil.ToFloat64(configValidationErrors); got != 2 {
+		t.Errorf("nacosbridge_config_validation_errors = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(configValid); got != 0 {
+		t.Errorf("nacosbridge_config_valid = %v, want 0", got)
+	}
+
+	s.reportConfigs([]configReport{{err: errors.New("failed to get secret")}})
+	if got := testutil.ToFloat64(configValidationErrors); got != 0 {
+		t.Errorf("nacosbridge_config_validation_errors = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(configValid); got != 0 {
+		t.Errorf("nacosbridge_config_valid = %v, want 0", got)
+	}
+}
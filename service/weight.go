@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// 实例默认权重
+	DEFAULT_WEIGHT = 10
+
+	// Nacos 允许的最大权重
+	MAX_WEIGHT = 10000
+
+	// 按就绪端点数量自动计算权重
+	WEIGHT_AUTO = "auto"
+)
+
+// portWeight 解析端口的权重配置, 端口配置优先于服务配置
+func portWeight(svc *corev1.Service, portName string) (float64, bool, error) {
+	value := GetSetting(svc, REGISTRY_WEIGHT)
+	if portName != "" {
+		if v := GetSetting(svc, REGISTRY_PORT_WEIGHT+portName); v != "" {
+			value = v
+		}
+	}
+	switch value {
+	case "":
+		return DEFAULT_WEIGHT, false, nil
+	case WEIGHT_AUTO:
+		return 0, true, nil
+	}
+	weight, err := strconv.ParseFloat(value, 64)
+	if err != nil || weight < 0 || weight > MAX_WEIGHT {
+		return 0, false, fmt.Errorf("invalid weight %q, must be %s or a number between 0 and %d", value, WEIGHT_AUTO, MAX_WEIGHT)
+	}
+	return weight, false, nil
+}
+
+// applyWeights 写入注册信息的权重
+// auto 模式下权重为就绪端点数量, NodePort 服务按每个节点上的就绪端点数量计算
+func applyWeights(svc *corev1.Service, cache *Cache, families []corev1.IPFamily, config *Config, serviceInfos []Service) error {
+	var (
+		autoWeight  float64
+		nodeWeights map[string]float64
+	)
+	for i := range serviceInfos {
+		weight, auto, err := portWeight(svc, serviceInfos[i].servicePort.Name)
+		if err != nil {
+			return err
+		}
+		if !auto {
+			serviceInfos[i].Weight = weight
+			continue
+		}
+
+		if nodeWeights == nil {
+			if autoWeight, nodeWeights, err = autoWeights(svc, cache, families, config); err != nil {
+				return err
+			}
+		}
+		serviceInfos[i].Weight = autoWeight
+		for ip, weight := range nodeWeights {
+			serviceInfos[i].setOverride(ip, func(o *InstanceOverride) {
+				o.Weight = &weight
+			})
+		}
+	}
+	return nil
+}
+
+// autoWeights 返回按就绪端点数量计算的权重, NodePort 服务同时返回每个节点地址的权重
+// NodePort 服务只有 externalTrafficPolicy 为 Local 时流量才留在所在节点, 否则各节点的流量会转发到全部端点, 使用默认权重
+func autoWeights(svc *corev1.Service, cache *Cache, families []corev1.IPFamily, config *Config) (float64, map[string]float64, error) {
+	endpoints, nodeEndpoints := readyEndpoints(svc, cache)
+	weights := make(map[string]float64)
+	if GetSetting(svc, REGISTRY_SERVICE_TYPE) != "external" || svc.Spec.Type != corev1.ServiceTypeNodePort {
+		return min(float64(endpoints), MAX_WEIGHT), weights, nil
+	}
+	if svc.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyLocal {
+		return DEFAULT_WEIGHT, weights, nil
+	}
+
+	nodeSelector, err := NewNodeSelector(svc, cache, config)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, node := range cache.nodes {
+		for _, address := range FilterAddresses(nodeSelector.Addresses(node), families) {
+			weights[address] = min(float64(nodeEndpoints[node.Name]), MAX_WEIGHT)
+		}
+	}
+	return min(float64(endpoints), MAX_WEIGHT), weights, nil
+}
+
+// readyEndpoints 统计服务的就绪端点数量以及每个节点上的就绪端点数量
+// 双栈服务的同一端点出现在多个 EndpointSlice 中, 按 TargetRef 或首个地址去重
+func readyEndpoints(svc *corev1.Service, cache *Cache) (int, map[string]int) {
+	seen := make(map[string]bool)
+	nodes := make(map[string]int)
+	for _, es := range cache.EndpointSlicesFor(svc) {
+		for _, ep := range es.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			key := ""
+			if ep.TargetRef != nil {
+				key = string(ep.TargetRef.UID)
+			}
+			if key == "" && len(ep.Addresses) > 0 {
+				key = ep.Addresses[0]
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if ep.NodeName != nil {
+				nodes[*ep.NodeName]++
+			}
+		}
+	}
+	return len(seen), nodes
+}
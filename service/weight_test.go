@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newWeightTestCache() *Cache {
+	cache := &Cache{}
+	for _, name := range []string{"node-a", "node-b"} {
+		cache.Insert(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{
+				Addresses:  []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: name + "-ip"}},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		})
+	}
+	nodeA, nodeB := "node-a", "node-b"
+	cache.Insert(&discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-abc",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "user"},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.1"}, NodeName: &nodeA},
+			{Addresses: []string{"10.0.0.2"}, NodeName: &nodeA},
+			{Addresses: []string{"10.0.0.3"}, NodeName: &nodeB},
+		},
+	})
+	return cache
+}
+
+func TestApplyWeightsAuto(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType string
+		specType    corev1.ServiceType
+		policy      corev1.ServiceExternalTrafficPolicy
+		weight      float64
+		nodeWeights map[string]float64
+	}{
+		{name: "cluster service", specType: corev1.ServiceTypeClusterIP, weight: 3},
+		{
+			name:        "nodeport local",
+			serviceType: "external",
+			specType:    corev1.ServiceTypeNodePort,
+			policy:      corev1.ServiceExternalTrafficPolicyLocal,
+			weight:      3,
+			nodeWeights: map[string]float64{"node-a-ip": 2, "node-b-ip": 1},
+		},
+		// Cluster 策略下节点会把流量转发到其它节点的端点, 按节点的端点数量计算权重没有意义
+		{
+			name:        "nodeport cluster",
+			serviceType: "external",
+			specType:    corev1.ServiceTypeNodePort,
+			policy:      corev1.ServiceExternalTrafficPolicyCluster,
+			weight:      DEFAULT_WEIGHT,
+		},
+	}
+	for _, tt := range tests {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "user",
+				Namespace:   "default",
+				Annotations: map[string]string{REGISTRY_WEIGHT: WEIGHT_AUTO},
+			},
+			Spec: corev1.ServiceSpec{Type: tt.specType, ExternalTrafficPolicy: tt.policy},
+		}
+		if tt.serviceType != "" {
+			svc.Annotations[REGISTRY_SERVICE_TYPE] = tt.serviceType
+		}
+		infos := []Service{{Name: "user", Port: 80}}
+		if err := applyWeights(svc, newWeightTestCache(), nil, &Config{}, infos); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if infos[0].Weight != tt.weight {
+			t.Errorf("%s: weight = %v, want %v", tt.name, infos[0].Weight, tt.weight)
+		}
+		if len(infos[0].Overrides) != len(tt.nodeWeights) {
+			t.Errorf("%s: overrides = %v, want node weights %v", tt.name, infos[0].Overrides, tt.nodeWeights)
+		}
+		for ip, want := range tt.nodeWeights {
+			if o := infos[0].Overrides[ip]; o.Weight == nil || *o.Weight != want {
+				t.Errorf("%s: weight of %s = %v, want %v", tt.name, ip, o.Weight, want)
+			}
+		}
+	}
+}